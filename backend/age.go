@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+	"github.com/browserpass/browserpass-native/v3/helpers"
+)
+
+// Age an encryption backend for passage-compatible stores, which keep `*.age` files
+// encrypted for the recipients listed in `.age-recipients` files
+type Age struct {
+	identitiesPath string
+	identities     []age.Identity
+}
+
+// NewAge creates a backend that uses the identities from the file at the given path
+func NewAge(identitiesPath string) *Age {
+	return &Age{identitiesPath: identitiesPath}
+}
+
+// Validate checks that the identities file can be read and contains at least one identity
+func (a *Age) Validate() error {
+	_, err := a.readIdentities()
+	return err
+}
+
+// Decrypt decrypts the contents of a password file using the identities
+// The recipients of age files are anonymous and age has no signatures, so the metadata is mostly empty.
+func (a *Age) Decrypt(ciphertext []byte) ([]byte, *Metadata, error) {
+	identities, err := a.readIdentities()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reader io.Reader = bytes.NewReader(ciphertext)
+	if bytes.HasPrefix(bytes.TrimSpace(ciphertext), []byte(armor.Header)) {
+		reader = armor.NewReader(reader)
+	}
+
+	decrypted, err := age.Decrypt(reader, identities...)
+	if err != nil {
+		return nil, nil, classifyAgeFailure(err)
+	}
+
+	var plaintext bytes.Buffer
+	if _, err := io.Copy(&plaintext, decrypted); err != nil {
+		helpers.WipeBytes(plaintext.Bytes())
+		return nil, nil, &Failure{Kind: FailureCorruptedFile, Err: err}
+	}
+
+	meta := &Metadata{
+		Recipients: []string{},
+		Signature:  SignatureMissing,
+	}
+	return plaintext.Bytes(), meta, nil
+}
+
+// Encrypt encrypts the contents of a password file for the given X25519 or SSH recipients
+func (a *Age) Encrypt(plaintext []byte, recipients []string) ([]byte, error) {
+	parsed := []age.Recipient{}
+	for _, recipient := range recipients {
+		var r age.Recipient
+		var err error
+		if strings.HasPrefix(recipient, "ssh-") {
+			r, err = agessh.ParseRecipient(recipient)
+		} else {
+			r, err = age.ParseX25519Recipient(recipient)
+		}
+		if err != nil {
+			return nil, &Failure{
+				Kind:   FailureUnusablePublicKey,
+				KeyIDs: []string{recipient},
+				Err:    fmt.Errorf("Invalid age recipient '%v': %s", recipient, err.Error()),
+			}
+		}
+		parsed = append(parsed, r)
+	}
+
+	var ciphertext bytes.Buffer
+	writer, err := age.Encrypt(&ciphertext, parsed...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return ciphertext.Bytes(), nil
+}
+
+// Probe checks whether any of the identities can unwrap the file key of the password file,
+// without decrypting the contents. The identities are never passphrase protected.
+func (a *Age) Probe(ciphertext []byte) (string, error) {
+	identities, err := a.readIdentities()
+	if err != nil {
+		return "", err
+	}
+
+	var reader io.Reader = bytes.NewReader(ciphertext)
+	if bytes.HasPrefix(bytes.TrimSpace(ciphertext), []byte(armor.Header)) {
+		reader = armor.NewReader(reader)
+	}
+
+	// age.Decrypt only reads and unwraps the header, the payload is decrypted while reading
+	if _, err := age.Decrypt(reader, identities...); err != nil {
+		err = classifyAgeFailure(err)
+		if failure, ok := err.(*Failure); ok && failure.Kind == FailureNoSecretKey {
+			return ProbeNoSecretKey, nil
+		}
+		return "", err
+	}
+	return ProbeUnlocked, nil
+}
+
+// classifyAgeFailure turns an error of unwrapping the file key into a Failure.
+// Once the identities are read, any other error means that the header is malformed.
+func classifyAgeFailure(err error) error {
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return &Failure{Kind: FailureNoSecretKey, KeyIDs: []string{}, Err: err}
+	}
+	return &Failure{Kind: FailureCorruptedFile, Err: err}
+}
+
+// EncryptedFor always fails with ErrHiddenRecipients, as age headers never identify the recipients
+func (a *Age) EncryptedFor(ciphertext []byte) ([]string, error) {
+	return nil, ErrHiddenRecipients
+}
+
+// VerifySignature always fails, as age has no signatures
+func (a *Age) VerifySignature(filePath string, signaturePath string) ([]string, error) {
+	return nil, fmt.Errorf("Signatures are not supported by the age backend")
+}
+
+// ListRecipients reads the recipients from the nearest `.age-recipients` file, same as passage,
+// falling back to the recipients of the identities if there is no such file
+func (a *Age) ListRecipients(filePath string) ([]string, error) {
+	recipients, err := helpers.DetectRecipients(filePath, helpers.AgeRecipientsFile)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return recipients, err
+	}
+
+	identities, err := a.readIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	recipients = []string{}
+	for _, identity := range identities {
+		switch identity := identity.(type) {
+		case *age.X25519Identity:
+			recipients = append(recipients, identity.Recipient().String())
+		default:
+			return nil, fmt.Errorf("Unable to find '%v' file", helpers.AgeRecipientsFile)
+		}
+	}
+	return recipients, nil
+}
+
+func (a *Age) readIdentities() ([]age.Identity, error) {
+	if a.identities != nil {
+		return a.identities, nil
+	}
+
+	if a.identitiesPath == "" {
+		return nil, fmt.Errorf("The identities path is not configured")
+	}
+
+	contents, err := os.ReadFile(a.identitiesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []age.Identity
+	if bytes.HasPrefix(bytes.TrimSpace(contents), []byte("-----BEGIN")) {
+		identity, err := agessh.ParseIdentity(contents)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the SSH identity: %s", err.Error())
+		}
+		identities = []age.Identity{identity}
+	} else {
+		identities, err = age.ParseIdentities(bytes.NewReader(contents))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the identities: %s", err.Error())
+		}
+	}
+
+	a.identities = identities
+	return identities, nil
+}
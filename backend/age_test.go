@@ -0,0 +1,80 @@
+package backend
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func makeTestIdentities(t *testing.T) (string, *age.X25519Identity) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Unable to generate a test identity: %v", err)
+	}
+
+	identitiesPath := filepath.Join(t.TempDir(), "identities")
+	contents := "# created: test\n" + identity.String() + "\n"
+	if err := os.WriteFile(identitiesPath, []byte(contents), 0600); err != nil {
+		t.Fatalf("Unable to write the test identities: %v", err)
+	}
+	return identitiesPath, identity
+}
+
+func Test_Age_EncryptDecryptRoundTrip(t *testing.T) {
+	// Arrange
+	identitiesPath, identity := makeTestIdentities(t)
+	backend := NewAge(identitiesPath)
+	expected := []byte("hunter2\nlogin: alice\n")
+
+	// Act
+	ciphertext, err := backend.Encrypt(expected, []string{identity.Recipient().String()})
+	if err != nil {
+		t.Fatalf("Error encrypting: %v", err)
+	}
+	actual, _, err := backend.Decrypt(ciphertext)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error decrypting: %v", err)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Fatalf("The decrypted contents '%s' do not match the original '%s'", actual, expected)
+	}
+}
+
+func Test_Age_RecipientsFallBackToIdentities(t *testing.T) {
+	// Arrange
+	identitiesPath, identity := makeTestIdentities(t)
+	backend := NewAge(identitiesPath)
+	storePath := t.TempDir()
+
+	// Act
+	recipients, err := backend.ListRecipients(filepath.Join(storePath, "site.age"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error listing recipients: %v", err)
+	}
+
+	if len(recipients) != 1 || recipients[0] != identity.Recipient().String() {
+		t.Fatalf("Expected the recipient of the identity, but got %v", recipients)
+	}
+}
+
+func Test_Age_InvalidRecipient(t *testing.T) {
+	// Arrange
+	identitiesPath, _ := makeTestIdentities(t)
+	backend := NewAge(identitiesPath)
+
+	// Act
+	_, err := backend.Encrypt([]byte("hunter2"), []string{"test@example.com"})
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected an error for an invalid recipient, but didn't get it")
+	}
+}
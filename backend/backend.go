@@ -0,0 +1,89 @@
+package backend
+
+import "errors"
+
+// Names of the supported encryption backends, as used in the store settings
+const (
+	NameGpg     = "gpg"
+	NameOpenPGP = "openpgp"
+	NameAge     = "age"
+)
+
+// Possible results of probing a password file
+const (
+	ProbeUnlocked    = "unlocked"
+	ProbeLocked      = "locked"
+	ProbeNoSecretKey = "no-secret-key"
+)
+
+// Backend an encryption backend used to read and write password files
+type Backend interface {
+	// Validate checks that the backend is configured correctly and can be used
+	Validate() error
+
+	// Decrypt decrypts the contents of a password file, and reports what was learned about it on the way
+	Decrypt(ciphertext []byte) ([]byte, *Metadata, error)
+
+	// Encrypt encrypts the contents of a password file for the given recipients
+	Encrypt(plaintext []byte, recipients []string) ([]byte, error)
+
+	// Probe checks whether the password file could be decrypted without any user interaction,
+	// without ever prompting for a passphrase
+	Probe(ciphertext []byte) (string, error)
+
+	// EncryptedFor lists the IDs of the keys a password file is encrypted for, without decrypting it.
+	// ErrHiddenRecipients is returned if the password file does not reveal them.
+	EncryptedFor(ciphertext []byte) ([]string, error)
+
+	// VerifySignature verifies a detached signature of a file, and returns the fingerprints
+	// of the signing key and of its primary key if the signature is valid
+	VerifySignature(filePath string, signaturePath string) ([]string, error)
+
+	// ListRecipients determines the recipients a password file at the given path must be encrypted for
+	ListRecipients(filePath string) ([]string, error)
+}
+
+// ErrHiddenRecipients the password file does not reveal the keys it is encrypted for
+var ErrHiddenRecipients = errors.New("The password file does not reveal its recipients")
+
+// Kinds of encryption failures that are worth telling apart, because the user can act on them
+const (
+	FailureNoSecretKey       = "noSecretKey"
+	FailureBadPassphrase     = "badPassphrase"
+	FailurePinentryCancelled = "pinentryCancelled"
+	FailureCardNotPresent    = "cardNotPresent"
+	FailureCorruptedFile     = "corruptedFile"
+	FailureUnusablePublicKey = "unusablePublicKey"
+)
+
+// Failure a classified error of an encryption backend
+type Failure struct {
+	Kind string
+	// KeyIDs the missing secret keys for FailureNoSecretKey, or the unusable recipients for FailureUnusablePublicKey
+	KeyIDs []string
+	Err    error
+}
+
+func (f *Failure) Error() string {
+	return f.Err.Error()
+}
+
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// Possible states of the signature of a password file
+const (
+	SignatureMissing = "missing"
+	SignatureInvalid = "invalid"
+	SignatureValid   = "valid"
+	SignatureTrusted = "trusted"
+)
+
+// Metadata what an encryption backend learned about a password file while decrypting it
+type Metadata struct {
+	DecryptionKeyID   string   `json:"decryptionKeyId"`
+	Recipients        []string `json:"recipients"`
+	SignerFingerprint string   `json:"signerFingerprint"`
+	Signature         string   `json:"signature"`
+}
@@ -0,0 +1,336 @@
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/helpers"
+)
+
+// Gpg an encryption backend that runs the gpg binary
+type Gpg struct {
+	gpgPath string
+}
+
+// NewGpg creates a backend that uses the gpg binary at the given path
+func NewGpg(gpgPath string) *Gpg {
+	return &Gpg{gpgPath: gpgPath}
+}
+
+// Validate checks that the gpg binary can be executed
+func (gpg *Gpg) Validate() error {
+	return helpers.ValidateGpgBinary(gpg.gpgPath)
+}
+
+// Decrypt decrypts the contents of a password file using the keys available to gpg-agent
+func (gpg *Gpg) Decrypt(ciphertext []byte) ([]byte, *Metadata, error) {
+	var stdout, stderr bytes.Buffer
+	// No --quiet here, as it also suppresses the ERROR status lines needed to classify failures
+	gpgOptions := []string{"--decrypt", "--yes", "--batch", "--status-fd", "2", "-"}
+
+	cmd := exec.Command(gpg.gpgPath, gpgOptions...)
+	cmd.Stdin = bytes.NewReader(ciphertext)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		helpers.WipeBytes(stdout.Bytes())
+		return nil, nil, classifyGpgFailure(err, stderr.Bytes())
+	}
+
+	return stdout.Bytes(), parseGpgStatus(stderr.Bytes()).metadata(), nil
+}
+
+// Encrypt encrypts the contents of a password file for the given recipients from the gpg keyring
+func (gpg *Gpg) Encrypt(plaintext []byte, recipients []string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	gpgOptions := []string{"--encrypt", "--yes", "--quiet", "--batch", "--status-fd", "2"}
+	for _, recipient := range recipients {
+		gpgOptions = append(gpgOptions, "--recipient", recipient)
+	}
+
+	cmd := exec.Command(gpg.gpgPath, gpgOptions...)
+	cmd.Stdin = bytes.NewReader(plaintext)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, classifyGpgFailure(err, stderr.Bytes())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Probe decrypts the password file with pinentry disabled and discards the output.
+// If a secret key is available but its passphrase is not cached in gpg-agent, gpg fails
+// with a cancelled operation instead of asking for the passphrase.
+func (gpg *Gpg) Probe(ciphertext []byte) (string, error) {
+	var stderr bytes.Buffer
+	gpgOptions := []string{"--decrypt", "--yes", "--batch", "--pinentry-mode", "cancel", "--status-fd", "2", "-"}
+
+	// The decrypted contents go straight to the null device and are never read by the host app
+	cmd := exec.Command(gpg.gpgPath, gpgOptions...)
+	cmd.Stdin = bytes.NewReader(ciphertext)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		err = classifyGpgFailure(err, stderr.Bytes())
+		if failure, ok := err.(*Failure); ok {
+			switch failure.Kind {
+			case FailureNoSecretKey:
+				return ProbeNoSecretKey, nil
+			case FailureBadPassphrase, FailurePinentryCancelled:
+				return ProbeLocked, nil
+			}
+		}
+		return "", err
+	}
+
+	return ProbeUnlocked, nil
+}
+
+// EncryptedFor lists the long IDs of the keys a password file is encrypted for, as reported
+// by gpg without decrypting anything. Files encrypted with hidden recipients (--throw-keyids) have zero key IDs.
+func (gpg *Gpg) EncryptedFor(ciphertext []byte) ([]string, error) {
+	var stderr bytes.Buffer
+	gpgOptions := []string{"--decrypt", "--list-only", "--batch", "--status-fd", "2", "-"}
+
+	cmd := exec.Command(gpg.gpgPath, gpgOptions...)
+	cmd.Stdin = bytes.NewReader(ciphertext)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, classifyGpgFailure(err, stderr.Bytes())
+	}
+
+	keyIDs := []string{}
+	for _, args := range parseGpgStatus(stderr.Bytes()).lines("ENC_TO") {
+		if len(args) == 0 || strings.Trim(args[0], "0") == "" {
+			return nil, ErrHiddenRecipients
+		}
+		keyIDs = append(keyIDs, args[0])
+	}
+	return keyIDs, nil
+}
+
+// VerifySignature verifies a detached signature using the public keys in the gpg keyring
+func (gpg *Gpg) VerifySignature(filePath string, signaturePath string) ([]string, error) {
+	var stderr bytes.Buffer
+	gpgOptions := []string{"--verify", "--batch", "--status-fd", "2", signaturePath, filePath}
+
+	cmd := exec.Command(gpg.gpgPath, gpgOptions...)
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	valid := parseGpgStatus(stderr.Bytes()).lines("VALIDSIG")
+	if err != nil || len(valid) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no valid signature found")
+		}
+		return nil, fmt.Errorf("Error: %s, Stderr: %s", err.Error(), stderr.String())
+	}
+
+	fingerprints := []string{}
+	for _, args := range valid {
+		// The fingerprint of the signing (sub)key comes first, the fingerprint of its primary key last
+		if len(args) > 0 {
+			fingerprints = append(fingerprints, args[0])
+		}
+		if len(args) > 9 {
+			fingerprints = append(fingerprints, args[9])
+		}
+	}
+	return fingerprints, nil
+}
+
+// ListRecipients reads the recipients from the nearest `.gpg-id` file
+func (gpg *Gpg) ListRecipients(filePath string) ([]string, error) {
+	return helpers.DetectGpgRecipients(filePath)
+}
+
+// Error codes of libgpg-error reported in the ERROR and FAILURE status lines
+const (
+	gpgErrUnknownPacket  = 2
+	gpgErrUnknownVersion = 3
+	gpgErrChecksum       = 10
+	gpgErrBadPassphrase  = 11
+	gpgErrInvalidPacket  = 14
+	gpgErrInvalidArmor   = 15
+	gpgErrWrongSecretKey = 18
+	gpgErrUnusablePubkey = 53
+	gpgErrNoData         = 58
+	gpgErrInvalidData    = 79
+	gpgErrNoPinentry     = 85
+	gpgErrBadData        = 89
+	gpgErrCanceled       = 99
+	gpgErrCardRemoved    = 110
+	gpgErrCardNotPresent = 112
+	gpgErrNoPassphrase   = 177
+	gpgErrFullyCanceled  = 198
+)
+
+// classifyGpgFailure turns a failed gpg run into a Failure based on the status lines,
+// or into a plain error with the gpg output if the failure is not recognized
+func classifyGpgFailure(err error, stderr []byte) error {
+	err = fmt.Errorf("Error: %s, Stderr: %s", err.Error(), string(stderr))
+	status := parseGpgStatus(stderr)
+
+	if invalid := status.lines("INV_RECP"); len(invalid) > 0 {
+		recipients := []string{}
+		for _, args := range invalid {
+			if len(args) > 1 {
+				recipients = append(recipients, args[1])
+			}
+		}
+		return &Failure{Kind: FailureUnusablePublicKey, KeyIDs: recipients, Err: err}
+	}
+
+	if len(status.lines("BAD_PASSPHRASE")) > 0 {
+		return &Failure{Kind: FailureBadPassphrase, Err: err}
+	}
+
+	for _, code := range status.errorCodes() {
+		switch code {
+		case gpgErrBadPassphrase:
+			return &Failure{Kind: FailureBadPassphrase, Err: err}
+		case gpgErrNoPinentry, gpgErrCanceled, gpgErrNoPassphrase, gpgErrFullyCanceled:
+			return &Failure{Kind: FailurePinentryCancelled, Err: err}
+		case gpgErrCardNotPresent, gpgErrCardRemoved:
+			return &Failure{Kind: FailureCardNotPresent, Err: err}
+		case gpgErrUnusablePubkey:
+			return &Failure{Kind: FailureUnusablePublicKey, KeyIDs: []string{}, Err: err}
+		case gpgErrUnknownPacket, gpgErrUnknownVersion, gpgErrChecksum, gpgErrInvalidPacket,
+			gpgErrInvalidArmor, gpgErrWrongSecretKey, gpgErrNoData, gpgErrInvalidData, gpgErrBadData:
+			return &Failure{Kind: FailureCorruptedFile, Err: err}
+		}
+	}
+
+	// Any other failure to use the secret key after launching pinentry means that the prompt could not be shown,
+	// e.g. a curses pinentry without a terminal
+	if len(status.lines("PINENTRY_LAUNCHED")) > 0 && len(status.lines("ERROR")) > 0 {
+		return &Failure{Kind: FailurePinentryCancelled, Err: err}
+	}
+
+	// gpg reports NO_SECKEY for each key it lacks, which is only a failure if it lacks all of them
+	encryptedFor := status.lines("ENC_TO")
+	missing := status.lines("NO_SECKEY")
+	if len(encryptedFor) > 0 && len(missing) == len(encryptedFor) {
+		keyIDs := []string{}
+		for _, args := range missing {
+			if len(args) > 0 {
+				keyIDs = append(keyIDs, args[0])
+			}
+		}
+		return &Failure{Kind: FailureNoSecretKey, KeyIDs: keyIDs, Err: err}
+	}
+
+	if len(status.lines("NODATA")) > 0 || len(status.lines("BADMDC")) > 0 {
+		return &Failure{Kind: FailureCorruptedFile, Err: err}
+	}
+
+	return err
+}
+
+// gpgStatus the machine readable status lines printed by gpg with `--status-fd`,
+// split into the keyword and its arguments
+type gpgStatus [][]string
+
+func parseGpgStatus(output []byte) gpgStatus {
+	status := gpgStatus{}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasPrefix(line, "[GNUPG:] ") {
+			continue
+		}
+		status = append(status, strings.Fields(strings.TrimPrefix(line, "[GNUPG:] ")))
+	}
+	return status
+}
+
+// lines returns the arguments of all status lines with the given keyword
+func (status gpgStatus) lines(keyword string) [][]string {
+	found := [][]string{}
+	for _, fields := range status {
+		if len(fields) > 0 && fields[0] == keyword {
+			found = append(found, fields[1:])
+		}
+	}
+	return found
+}
+
+// errorCodes returns the libgpg-error codes of all ERROR and FAILURE status lines,
+// without the error source that is encoded in the upper bits
+func (status gpgStatus) errorCodes() []int {
+	codes := []int{}
+	for _, keyword := range []string{"ERROR", "FAILURE"} {
+		for _, args := range status.lines(keyword) {
+			if len(args) < 2 {
+				continue
+			}
+			if code, err := strconv.Atoi(args[1]); err == nil {
+				codes = append(codes, code&0xFFFF)
+			}
+		}
+	}
+	return codes
+}
+
+// metadata collects the decryption key, the recipients and the signature from the status lines of a decryption
+func (status gpgStatus) metadata() *Metadata {
+	meta := &Metadata{
+		Recipients: []string{},
+		Signature:  SignatureMissing,
+	}
+
+	for _, args := range status.lines("ENC_TO") {
+		if len(args) > 0 {
+			meta.Recipients = append(meta.Recipients, args[0])
+		}
+	}
+	if args := status.lines("DECRYPTION_KEY"); len(args) > 0 && len(args[0]) > 0 {
+		meta.DecryptionKeyID = keyIDOfFingerprint(args[0][0])
+	}
+
+	for _, keyword := range []string{"BADSIG", "EXPSIG", "EXPKEYSIG", "REVKEYSIG", "ERRSIG"} {
+		for _, args := range status.lines(keyword) {
+			meta.Signature = SignatureInvalid
+			// ERRSIG has the fingerprint of the signing key as the last argument since gpg 2.2.7
+			if keyword == "ERRSIG" && len(args) > 6 && args[6] != "-" {
+				meta.SignerFingerprint = args[6]
+			} else if len(args) > 0 {
+				meta.SignerFingerprint = args[0]
+			}
+		}
+	}
+	if meta.Signature == SignatureInvalid {
+		return meta
+	}
+
+	if len(status.lines("GOODSIG")) > 0 {
+		meta.Signature = SignatureValid
+		if len(status.lines("TRUST_FULLY")) > 0 || len(status.lines("TRUST_ULTIMATE")) > 0 {
+			meta.Signature = SignatureTrusted
+		}
+	}
+	for _, args := range status.lines("VALIDSIG") {
+		// The fingerprint of the primary key follows the fingerprint of the signing (sub)key
+		if len(args) > 9 {
+			meta.SignerFingerprint = args[9]
+		} else if len(args) > 0 {
+			meta.SignerFingerprint = args[0]
+		}
+	}
+
+	return meta
+}
+
+// keyIDOfFingerprint returns the long key ID, which are the last 16 hex digits of a v4 fingerprint
+func keyIDOfFingerprint(fingerprint string) string {
+	if len(fingerprint) > 16 {
+		return fingerprint[len(fingerprint)-16:]
+	}
+	return fingerprint
+}
@@ -0,0 +1,231 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// makeTestGpgHome points gpg to an empty home directory for the duration of the test,
+// and stops the gpg-agent started in it afterwards. It is not created in t.TempDir,
+// as the path of the agent socket must stay short.
+func makeTestGpgHome(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("The gpg binary is not available")
+	}
+
+	home, err := os.MkdirTemp("", "gpg")
+	if err != nil {
+		t.Fatalf("Unable to create the test gpg home: %v", err)
+	}
+	t.Setenv("GNUPGHOME", home)
+	t.Cleanup(func() {
+		exec.Command("gpgconf", "--kill", "gpg-agent").Run()
+		os.RemoveAll(home)
+	})
+}
+
+// runTestGpg runs gpg in batch mode with the passphrase given on the command line
+func runTestGpg(t *testing.T, passphrase string, args ...string) string {
+	gpgOptions := append([]string{"--batch", "--yes", "--pinentry-mode", "loopback", "--passphrase", passphrase}, args...)
+	output, err := exec.Command("gpg", gpgOptions...).Output()
+	if err != nil {
+		t.Fatalf("Unable to run gpg %v: %v", args, err)
+	}
+	return string(output)
+}
+
+// generateTestGpgKey generates a key and returns its fingerprint
+func generateTestGpgKey(t *testing.T, email string, passphrase string) string {
+	runTestGpg(t, passphrase, "--quick-generate-key", email, "default", "default", "never")
+	for _, line := range strings.Split(runTestGpg(t, "", "--list-keys", "--with-colons", email), "\n") {
+		if fields := strings.Split(line, ":"); fields[0] == "fpr" {
+			return fields[9]
+		}
+	}
+	t.Fatalf("Unable to find the fingerprint of the test key")
+	return ""
+}
+
+func Test_ParseGpgStatus_ErrorCodes(t *testing.T) {
+	// Arrange
+	stderr := []byte("[GNUPG:] ENC_TO 095C51894B224C1D 1 0\n" +
+		"gpg: public key decryption failed: Operation cancelled\n" +
+		"[GNUPG:] ERROR pkdecrypt_failed 67108963\r\n" +
+		"[GNUPG:] DECRYPTION_FAILED\n" +
+		"[GNUPG:] FAILURE decrypt 33554449\n")
+
+	// Act
+	status := parseGpgStatus(stderr)
+
+	// Assert
+	if len(status) != 4 {
+		t.Fatalf("Expected 4 status lines, but got %v", status)
+	}
+
+	encTo := status.lines("ENC_TO")
+	if !reflect.DeepEqual(encTo, [][]string{{"095C51894B224C1D", "1", "0"}}) {
+		t.Fatalf("Unexpected ENC_TO lines: %v", encTo)
+	}
+
+	codes := status.errorCodes()
+	if !reflect.DeepEqual(codes, []int{gpgErrCanceled, 17}) {
+		t.Fatalf("Expected the error codes without the error source, but got %v", codes)
+	}
+}
+
+func Test_ClassifyGpgFailure_NoSecretKey(t *testing.T) {
+	// Arrange
+	stderr := []byte("[GNUPG:] ENC_TO 775AC05299EB960F 1 0\n" +
+		"[GNUPG:] NO_SECKEY 775AC05299EB960F\n" +
+		"[GNUPG:] DECRYPTION_FAILED\n")
+
+	// Act
+	err := classifyGpgFailure(errors.New("exit status 2"), stderr)
+
+	// Assert
+	failure, ok := err.(*Failure)
+	if !ok || failure.Kind != FailureNoSecretKey {
+		t.Fatalf("Expected a failure of kind '%v', but got %v", FailureNoSecretKey, err)
+	}
+
+	if !reflect.DeepEqual(failure.KeyIDs, []string{"775AC05299EB960F"}) {
+		t.Fatalf("Expected the missing key ID, but got %v", failure.KeyIDs)
+	}
+}
+
+func Test_ClassifyGpgFailure_OneOfSecretKeysMissing(t *testing.T) {
+	// Arrange
+	stderr := []byte("[GNUPG:] ENC_TO 775AC05299EB960F 1 0\n" +
+		"[GNUPG:] ENC_TO E4DE758690AA29ED 1 0\n" +
+		"[GNUPG:] NO_SECKEY 775AC05299EB960F\n" +
+		"[GNUPG:] BADMDC\n" +
+		"[GNUPG:] DECRYPTION_FAILED\n")
+
+	// Act
+	err := classifyGpgFailure(errors.New("exit status 2"), stderr)
+
+	// Assert
+	failure, ok := err.(*Failure)
+	if !ok || failure.Kind != FailureCorruptedFile {
+		t.Fatalf("Expected a failure of kind '%v', but got %v", FailureCorruptedFile, err)
+	}
+}
+
+func Test_ClassifyGpgFailure_UnusablePublicKey(t *testing.T) {
+	// Arrange
+	stderr := []byte("[GNUPG:] INV_RECP 0 nobody@example.com\n" +
+		"[GNUPG:] FAILURE encrypt 167772380\n")
+
+	// Act
+	err := classifyGpgFailure(errors.New("exit status 2"), stderr)
+
+	// Assert
+	failure, ok := err.(*Failure)
+	if !ok || failure.Kind != FailureUnusablePublicKey {
+		t.Fatalf("Expected a failure of kind '%v', but got %v", FailureUnusablePublicKey, err)
+	}
+
+	if !reflect.DeepEqual(failure.KeyIDs, []string{"nobody@example.com"}) {
+		t.Fatalf("Expected the unusable recipient, but got %v", failure.KeyIDs)
+	}
+}
+
+func Test_ClassifyGpgFailure_Unknown(t *testing.T) {
+	// Arrange
+	stderr := []byte("gpg: something unexpected happened\n")
+
+	// Act
+	err := classifyGpgFailure(errors.New("exit status 2"), stderr)
+
+	// Assert
+	if _, ok := err.(*Failure); ok {
+		t.Fatalf("Expected a plain error for an unknown failure, but got %v", err)
+	}
+}
+
+func Test_GpgStatus_Metadata(t *testing.T) {
+	// Arrange
+	stderr := []byte("[GNUPG:] ENC_TO E4DE758690AA29ED 1 0\n" +
+		"[GNUPG:] ENC_TO 775AC05299EB960F 1 0\n" +
+		"[GNUPG:] DECRYPTION_KEY 142EDC3E3A3EE0822C6209D2E4DE758690AA29ED 700D51FC72377876B48FDF5823B047A34CC43578 u\n" +
+		"[GNUPG:] GOODSIG 23B047A34CC43578 Test User <test@example.com>\n" +
+		"[GNUPG:] VALIDSIG 142EDC3E3A3EE0822C6209D2E4DE758690AA29ED 2026-10-19 1792413978 0 4 0 1 10 00 700D51FC72377876B48FDF5823B047A34CC43578\n" +
+		"[GNUPG:] TRUST_UNDEFINED 0 pgp\n")
+	expected := &Metadata{
+		DecryptionKeyID:   "E4DE758690AA29ED",
+		Recipients:        []string{"E4DE758690AA29ED", "775AC05299EB960F"},
+		SignerFingerprint: "700D51FC72377876B48FDF5823B047A34CC43578",
+		Signature:         SignatureValid,
+	}
+
+	// Act
+	actual := parseGpgStatus(stderr).metadata()
+
+	// Assert
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Expected metadata %+v, but got %+v", expected, actual)
+	}
+}
+
+func Test_GpgStatus_MetadataBadSignature(t *testing.T) {
+	// Arrange
+	stderr := []byte("[GNUPG:] ENC_TO E4DE758690AA29ED 1 0\n" +
+		"[GNUPG:] BADSIG 23B047A34CC43578 Test User <test@example.com>\n")
+
+	// Act
+	meta := parseGpgStatus(stderr).metadata()
+
+	// Assert
+	if meta.Signature != SignatureInvalid {
+		t.Fatalf("Expected the signature '%v', but got '%v'", SignatureInvalid, meta.Signature)
+	}
+
+	if meta.SignerFingerprint != "23B047A34CC43578" {
+		t.Fatalf("Expected the key ID of the signer, but got '%v'", meta.SignerFingerprint)
+	}
+}
+
+func Test_Gpg_Probe(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+		deleteKey  bool
+		expected   string
+	}{
+		{name: "key without passphrase", passphrase: "", expected: ProbeUnlocked},
+		{name: "key with an uncached passphrase", passphrase: "correct horse", expected: ProbeLocked},
+		{name: "secret key not available", passphrase: "", deleteKey: true, expected: ProbeNoSecretKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			makeTestGpgHome(t)
+			fingerprint := generateTestGpgKey(t, "test@example.com", test.passphrase)
+			gpg := NewGpg("gpg")
+			ciphertext, err := gpg.Encrypt([]byte("hunter2"), []string{"test@example.com"})
+			if err != nil {
+				t.Fatalf("Error encrypting: %v", err)
+			}
+			if test.deleteKey {
+				runTestGpg(t, "", "--delete-secret-keys", fingerprint)
+			}
+
+			// Act
+			state, err := gpg.Probe(ciphertext)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Error probing: %v", err)
+			}
+
+			if state != test.expected {
+				t.Fatalf("Expected the state '%v', but got '%v'", test.expected, state)
+			}
+		})
+	}
+}
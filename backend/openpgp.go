@@ -0,0 +1,338 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/browserpass/browserpass-native/v3/helpers"
+)
+
+// OpenPGP an encryption backend implemented in pure Go, which reads the keys from a keyring file
+// and therefore works without GnuPG installed. The keyring file (armored or binary) must contain
+// the unprotected secret keys used for decryption, and the public keys of all recipients.
+type OpenPGP struct {
+	keyringPath string
+	keyring     openpgp.EntityList
+}
+
+// NewOpenPGP creates a backend that uses the keys from the keyring file at the given path
+func NewOpenPGP(keyringPath string) *OpenPGP {
+	return &OpenPGP{keyringPath: keyringPath}
+}
+
+// Validate checks that the keyring file can be read and contains at least one key
+func (pgp *OpenPGP) Validate() error {
+	_, err := pgp.readKeyring()
+	return err
+}
+
+// Decrypt decrypts the contents of a password file using the secret keys from the keyring
+func (pgp *OpenPGP) Decrypt(ciphertext []byte) ([]byte, *Metadata, error) {
+	keyring, err := pgp.readKeyring()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	message, err := openpgp.ReadMessage(dearmor(ciphertext), keyring, nil, nil)
+	if err != nil {
+		return nil, nil, classifyOpenPGPFailure(err, ciphertext)
+	}
+
+	var plaintext bytes.Buffer
+	if _, err := io.Copy(&plaintext, message.UnverifiedBody); err != nil {
+		helpers.WipeBytes(plaintext.Bytes())
+		return nil, nil, classifyOpenPGPFailure(err, ciphertext)
+	}
+
+	return plaintext.Bytes(), messageMetadata(message), nil
+}
+
+// Encrypt encrypts the contents of a password file for the given recipients from the keyring
+func (pgp *OpenPGP) Encrypt(plaintext []byte, recipients []string) ([]byte, error) {
+	keyring, err := pgp.readKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []*openpgp.Entity{}
+	for _, recipient := range recipients {
+		entity := findEntity(keyring, recipient)
+		if entity == nil {
+			return nil, &Failure{
+				Kind:   FailureUnusablePublicKey,
+				KeyIDs: []string{recipient},
+				Err:    fmt.Errorf("Unable to find the public key of the recipient '%v' in the keyring", recipient),
+			}
+		}
+		entities = append(entities, entity)
+	}
+
+	var ciphertext bytes.Buffer
+	writer, err := openpgp.Encrypt(&ciphertext, entities, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return ciphertext.Bytes(), nil
+}
+
+// Probe looks for the secret keys the password file is encrypted for in the keyring,
+// without decrypting anything. Passphrase protected keys can never be used by this backend.
+func (pgp *OpenPGP) Probe(ciphertext []byte) (string, error) {
+	keyring, err := pgp.readKeyring()
+	if err != nil {
+		return "", err
+	}
+
+	keyIDs, err := encryptedKeyIDs(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	result := ProbeNoSecretKey
+	for _, keyID := range keyIDs {
+		for _, key := range keyring.KeysByIdUsage(keyID, packet.KeyFlagEncryptCommunications|packet.KeyFlagEncryptStorage) {
+			if key.PrivateKey == nil {
+				continue
+			}
+			if !key.PrivateKey.Encrypted {
+				return ProbeUnlocked, nil
+			}
+			result = ProbeLocked
+		}
+	}
+	return result, nil
+}
+
+// EncryptedFor lists the long IDs of the keys a password file is encrypted for, read from its packets
+func (pgp *OpenPGP) EncryptedFor(ciphertext []byte) ([]string, error) {
+	ids, err := encryptedKeyIDs(ciphertext)
+	if err != nil {
+		return nil, classifyOpenPGPFailure(err, ciphertext)
+	}
+
+	keyIDs := []string{}
+	for _, keyID := range ids {
+		// Hidden recipients are stored with a zero key ID
+		if keyID == 0 {
+			return nil, ErrHiddenRecipients
+		}
+		keyIDs = append(keyIDs, fmt.Sprintf("%016X", keyID))
+	}
+	return keyIDs, nil
+}
+
+// VerifySignature verifies a detached signature (binary or ASCII armored) using the public keys from the keyring
+func (pgp *OpenPGP) VerifySignature(filePath string, signaturePath string) ([]string, error) {
+	keyring, err := pgp.readKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := os.ReadFile(signaturePath)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, signer, err := openpgp.VerifyDetachedSignature(keyring, bytes.NewReader(signed), dearmor(signature), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same as for gpg, the fingerprint of a signing subkey comes first, the fingerprint of its primary key last
+	fingerprints := []string{}
+	if sig.IssuerKeyId != nil {
+		for _, subkey := range signer.Subkeys {
+			if subkey.PublicKey.KeyId == *sig.IssuerKeyId {
+				fingerprints = append(fingerprints, strings.ToUpper(hex.EncodeToString(subkey.PublicKey.Fingerprint)))
+			}
+		}
+	}
+	return append(fingerprints, strings.ToUpper(hex.EncodeToString(signer.PrimaryKey.Fingerprint))), nil
+}
+
+// ListRecipients reads the recipients from the nearest `.gpg-id` file
+func (pgp *OpenPGP) ListRecipients(filePath string) ([]string, error) {
+	return helpers.DetectGpgRecipients(filePath)
+}
+
+func (pgp *OpenPGP) readKeyring() (openpgp.EntityList, error) {
+	if pgp.keyring != nil {
+		return pgp.keyring, nil
+	}
+
+	if pgp.keyringPath == "" {
+		return nil, fmt.Errorf("The keyring path is not configured")
+	}
+
+	contents, err := os.ReadFile(pgp.keyringPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring, err := readKeys(contents)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the keyring: %s", err.Error())
+	}
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("The keyring does not contain any keys")
+	}
+
+	pgp.keyring = keyring
+	return keyring, nil
+}
+
+// readKeys reads all keys from binary data, or from all ASCII armored blocks,
+// so that the keyring can be assembled by concatenating multiple exported keys
+func readKeys(data []byte) (openpgp.EntityList, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		return openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+
+	// armor.Decode reuses a large enough bufio.Reader, which allows reading the blocks one by one
+	reader := bufio.NewReader(bytes.NewReader(data))
+	keyring := openpgp.EntityList{}
+	for {
+		block, err := armor.Decode(reader)
+		if err == io.EOF {
+			return keyring, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entities, err := openpgp.ReadKeyRing(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		keyring = append(keyring, entities...)
+	}
+}
+
+// encryptedKeyIDs returns the IDs of the keys a message is encrypted for
+func encryptedKeyIDs(ciphertext []byte) ([]uint64, error) {
+	keyIDs := []uint64{}
+	packets := packet.NewReader(dearmor(ciphertext))
+	for {
+		p, err := packets.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		encryptedKey, ok := p.(*packet.EncryptedKey)
+		if !ok {
+			// Encrypted session keys always come first, the encrypted data follows them
+			return keyIDs, nil
+		}
+		keyIDs = append(keyIDs, encryptedKey.KeyId)
+	}
+}
+
+// messageMetadata collects the decryption key, the recipients and the signature of a fully read message.
+// There is no trust model without GnuPG, so a signature is at most valid, but never trusted.
+func messageMetadata(message *openpgp.MessageDetails) *Metadata {
+	meta := &Metadata{
+		Recipients: []string{},
+		Signature:  SignatureMissing,
+	}
+
+	for _, keyID := range message.EncryptedToKeyIds {
+		meta.Recipients = append(meta.Recipients, fmt.Sprintf("%016X", keyID))
+	}
+	if message.DecryptedWith.PublicKey != nil {
+		meta.DecryptionKeyID = fmt.Sprintf("%016X", message.DecryptedWith.PublicKey.KeyId)
+	}
+
+	if message.IsSigned {
+		switch {
+		case message.SignedBy != nil:
+			meta.SignerFingerprint = strings.ToUpper(hex.EncodeToString(message.SignedBy.Entity.PrimaryKey.Fingerprint))
+		case len(message.SignedByFingerprint) > 0:
+			meta.SignerFingerprint = strings.ToUpper(hex.EncodeToString(message.SignedByFingerprint))
+		default:
+			meta.SignerFingerprint = fmt.Sprintf("%016X", message.SignedByKeyId)
+		}
+
+		meta.Signature = SignatureInvalid
+		if message.SignedBy != nil && message.SignatureError == nil {
+			meta.Signature = SignatureValid
+		}
+	}
+
+	return meta
+}
+
+// classifyOpenPGPFailure turns a decryption error into a Failure if the cause is known
+func classifyOpenPGPFailure(err error, ciphertext []byte) error {
+	switch err.(type) {
+	case pgperrors.StructuralError, pgperrors.SignatureError, pgperrors.UnsupportedError:
+		return &Failure{Kind: FailureCorruptedFile, Err: err}
+	}
+	if err == pgperrors.ErrKeyIncorrect {
+		keyIDs := []string{}
+		if ids, idsErr := encryptedKeyIDs(ciphertext); idsErr == nil {
+			for _, id := range ids {
+				keyIDs = append(keyIDs, fmt.Sprintf("%016X", id))
+			}
+		}
+		return &Failure{Kind: FailureNoSecretKey, KeyIDs: keyIDs, Err: err}
+	}
+	if err == io.ErrUnexpectedEOF {
+		return &Failure{Kind: FailureCorruptedFile, Err: err}
+	}
+	return err
+}
+
+// dearmor returns a reader of the binary data, whether the data is ASCII armored or not
+func dearmor(data []byte) io.Reader {
+	if block, err := armor.Decode(bytes.NewReader(data)); err == nil {
+		return block.Body
+	}
+	return bytes.NewReader(data)
+}
+
+// findEntity finds a key by a recipient as written in `.gpg-id`: a fingerprint, a key ID or an email address
+func findEntity(keyring openpgp.EntityList, recipient string) *openpgp.Entity {
+	recipient = strings.TrimSpace(recipient)
+	keyID := strings.ToUpper(strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(recipient, "0x"), "0X"), "!"))
+
+	for _, entity := range keyring {
+		fingerprints := []string{strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint))}
+		for _, subkey := range entity.Subkeys {
+			fingerprints = append(fingerprints, strings.ToUpper(hex.EncodeToString(subkey.PublicKey.Fingerprint)))
+		}
+		for _, fingerprint := range fingerprints {
+			if len(keyID) >= 8 && strings.HasSuffix(fingerprint, keyID) {
+				return entity
+			}
+		}
+
+		for _, identity := range entity.Identities {
+			email := strings.Trim(recipient, "<>")
+			if strings.EqualFold(identity.UserId.Email, email) || identity.Name == recipient {
+				return entity
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+func makeTestKeyring(t *testing.T) string {
+	entity, err := openpgp.NewEntity("Test User", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("Unable to generate a test key: %v", err)
+	}
+
+	var keyring bytes.Buffer
+	if err := entity.SerializePrivate(&keyring, nil); err != nil {
+		t.Fatalf("Unable to serialize the test key: %v", err)
+	}
+
+	keyringPath := filepath.Join(t.TempDir(), "keyring.gpg")
+	if err := os.WriteFile(keyringPath, keyring.Bytes(), 0600); err != nil {
+		t.Fatalf("Unable to write the test keyring: %v", err)
+	}
+	return keyringPath
+}
+
+func Test_OpenPGP_EncryptDecryptRoundTrip(t *testing.T) {
+	// Arrange
+	pgp := NewOpenPGP(makeTestKeyring(t))
+	expected := []byte("hunter2\nlogin: alice\n")
+
+	// Act
+	ciphertext, err := pgp.Encrypt(expected, []string{"test@example.com"})
+	if err != nil {
+		t.Fatalf("Error encrypting: %v", err)
+	}
+	actual, _, err := pgp.Decrypt(ciphertext)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error decrypting: %v", err)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Fatalf("The decrypted contents '%s' do not match the original '%s'", actual, expected)
+	}
+}
+
+func Test_OpenPGP_UnknownRecipient(t *testing.T) {
+	// Arrange
+	pgp := NewOpenPGP(makeTestKeyring(t))
+
+	// Act
+	_, err := pgp.Encrypt([]byte("hunter2"), []string{"stranger@example.com"})
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected an error for an unknown recipient, but didn't get it")
+	}
+}
+
+func Test_OpenPGP_ProbeUnlocked(t *testing.T) {
+	// Arrange
+	pgp := NewOpenPGP(makeTestKeyring(t))
+	ciphertext, err := pgp.Encrypt([]byte("hunter2"), []string{"test@example.com"})
+	if err != nil {
+		t.Fatalf("Error encrypting: %v", err)
+	}
+
+	// Act
+	state, err := pgp.Probe(ciphertext)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error probing: %v", err)
+	}
+
+	if state != ProbeUnlocked {
+		t.Fatalf("Expected the state '%v', but got '%v'", ProbeUnlocked, state)
+	}
+}
+
+func Test_OpenPGP_ProbeNoSecretKey(t *testing.T) {
+	// Arrange
+	other := NewOpenPGP(makeTestKeyring(t))
+	ciphertext, err := other.Encrypt([]byte("hunter2"), []string{"test@example.com"})
+	if err != nil {
+		t.Fatalf("Error encrypting: %v", err)
+	}
+	pgp := NewOpenPGP(makeTestKeyring(t))
+
+	// Act
+	state, err := pgp.Probe(ciphertext)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error probing: %v", err)
+	}
+
+	if state != ProbeNoSecretKey {
+		t.Fatalf("Expected the state '%v', but got '%v'", ProbeNoSecretKey, state)
+	}
+}
+
+func Test_OpenPGP_EncryptedFor(t *testing.T) {
+	// Arrange
+	pgp := NewOpenPGP(makeTestKeyring(t))
+	other := NewOpenPGP(makeTestKeyring(t))
+	ciphertext, err := pgp.Encrypt([]byte("hunter2"), []string{"test@example.com"})
+	if err != nil {
+		t.Fatalf("Error encrypting: %v", err)
+	}
+	empty, err := pgp.Encrypt([]byte{}, []string{"test@example.com"})
+	if err != nil {
+		t.Fatalf("Error encrypting: %v", err)
+	}
+	otherCiphertext, err := other.Encrypt([]byte("hunter2"), []string{"test@example.com"})
+	if err != nil {
+		t.Fatalf("Error encrypting: %v", err)
+	}
+
+	// Act
+	keyIDs, err := pgp.EncryptedFor(ciphertext)
+	if err != nil {
+		t.Fatalf("Error listing the keys: %v", err)
+	}
+	expected, err := pgp.EncryptedFor(empty)
+	if err != nil {
+		t.Fatalf("Error listing the keys: %v", err)
+	}
+	otherKeyIDs, err := pgp.EncryptedFor(otherCiphertext)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error listing the keys: %v", err)
+	}
+
+	if len(keyIDs) != 1 || len(keyIDs[0]) != 16 || len(expected) != 1 || keyIDs[0] != expected[0] {
+		t.Fatalf("Expected the same single key ID for the same recipient, but got %v and %v", keyIDs, expected)
+	}
+
+	if len(otherKeyIDs) != 1 || otherKeyIDs[0] == keyIDs[0] {
+		t.Fatalf("Expected another key ID for another key of the same user ID, but got %v", otherKeyIDs)
+	}
+}
+
+func Test_OpenPGP_VerifySignature(t *testing.T) {
+	// Arrange
+	pgp := NewOpenPGP(makeTestKeyring(t))
+	keyring, err := pgp.readKeyring()
+	if err != nil {
+		t.Fatalf("Error reading the keyring: %v", err)
+	}
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, ".gpg-id")
+	contents := []byte("test@example.com\n")
+	var signature bytes.Buffer
+	if err := openpgp.DetachSign(&signature, keyring[0], bytes.NewReader(contents), nil); err != nil {
+		t.Fatalf("Error signing: %v", err)
+	}
+	if err := os.WriteFile(filePath, contents, 0644); err != nil {
+		t.Fatalf("Error writing the signed file: %v", err)
+	}
+	if err := os.WriteFile(filePath+".sig", signature.Bytes(), 0644); err != nil {
+		t.Fatalf("Error writing the signature: %v", err)
+	}
+
+	// Act
+	fingerprints, err := pgp.VerifySignature(filePath, filePath+".sig")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error verifying: %v", err)
+	}
+
+	expected := strings.ToUpper(hex.EncodeToString(keyring[0].PrimaryKey.Fingerprint))
+	if len(fingerprints) != 1 || fingerprints[0] != expected {
+		t.Fatalf("Expected the fingerprint '%v', but got %v", expected, fingerprints)
+	}
+}
+
+func Test_OpenPGP_VerifySignatureBySubkey(t *testing.T) {
+	// Arrange
+	entity, err := openpgp.NewEntity("Test User", "", "test@example.com", nil)
+	if err == nil {
+		err = entity.AddSigningSubkey(nil)
+	}
+	if err != nil {
+		t.Fatalf("Unable to generate a test key with a signing subkey: %v", err)
+	}
+	var keyring bytes.Buffer
+	if err := entity.Serialize(&keyring); err != nil {
+		t.Fatalf("Unable to serialize the test key: %v", err)
+	}
+
+	dir := t.TempDir()
+	keyringPath := filepath.Join(dir, "keyring.gpg")
+	filePath := filepath.Join(dir, ".gpg-id")
+	contents := []byte("test@example.com\n")
+	var signature bytes.Buffer
+	if err := openpgp.DetachSign(&signature, entity, bytes.NewReader(contents), nil); err != nil {
+		t.Fatalf("Error signing: %v", err)
+	}
+	for path, data := range map[string][]byte{keyringPath: keyring.Bytes(), filePath: contents, filePath + ".sig": signature.Bytes()} {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Error writing the test file '%v': %v", path, err)
+		}
+	}
+
+	// Act
+	fingerprints, err := NewOpenPGP(keyringPath).VerifySignature(filePath, filePath+".sig")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error verifying: %v", err)
+	}
+
+	expected := []string{
+		strings.ToUpper(hex.EncodeToString(entity.Subkeys[len(entity.Subkeys)-1].PublicKey.Fingerprint)),
+		strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)),
+	}
+	if len(fingerprints) != 2 || fingerprints[0] != expected[0] || fingerprints[1] != expected[1] {
+		t.Fatalf("Expected the fingerprints of the signing subkey and its primary key %v, but got %v", expected, fingerprints)
+	}
+}
+
+func Test_OpenPGP_VerifySignatureOfModifiedFile(t *testing.T) {
+	// Arrange
+	pgp := NewOpenPGP(makeTestKeyring(t))
+	keyring, err := pgp.readKeyring()
+	if err != nil {
+		t.Fatalf("Error reading the keyring: %v", err)
+	}
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, ".gpg-id")
+	var signature bytes.Buffer
+	if err := openpgp.DetachSign(&signature, keyring[0], bytes.NewReader([]byte("test@example.com\n")), nil); err != nil {
+		t.Fatalf("Error signing: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte("test@example.com\nintruder@example.com\n"), 0644); err != nil {
+		t.Fatalf("Error writing the signed file: %v", err)
+	}
+	if err := os.WriteFile(filePath+".sig", signature.Bytes(), 0644); err != nil {
+		t.Fatalf("Error writing the signature: %v", err)
+	}
+
+	// Act
+	_, err = pgp.VerifySignature(filePath, filePath+".sig")
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected an error for a modified file, but didn't get it")
+	}
+}
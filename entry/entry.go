@@ -0,0 +1,247 @@
+package entry
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/helpers"
+)
+
+// Canonical names of the well-known fields of a password entry
+const (
+	FieldUsername = "username"
+	FieldURL      = "url"
+	FieldOTP      = "otp"
+)
+
+// DefaultAliases keys of `key: value` lines recognized as the well-known fields, case-insensitive
+var DefaultAliases = map[string][]string{
+	FieldUsername: {"login", "username", "user"},
+	FieldURL:      {"url", "uri", "website", "site", "link", "launch"},
+	FieldOTP:      {"otp", "totp"},
+}
+
+// Field a custom `key: value` line of a password entry
+type Field struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Entry a password entry parsed according to the pass conventions:
+// the password on the first line, followed by `key: value` lines and free-form notes
+type Entry struct {
+	Password string   `json:"password"`
+	Username string   `json:"username"`
+	URLs     []string `json:"urls"`
+	OTP      []string `json:"otp"`
+	Fields   []Field  `json:"fields"`
+	Notes    string   `json:"notes"`
+}
+
+// Parse parses the decrypted contents of a password entry. If the entry has no username field,
+// the username is taken from the file name without the extension. The aliases override DefaultAliases per canonical field.
+func Parse(contents string, file string, aliases map[string][]string) *Entry {
+	entry := &Entry{
+		URLs:   []string{},
+		OTP:    []string{},
+		Fields: []Field{},
+	}
+
+	lines := strings.Split(contents, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	entry.Password = lines[0]
+
+	notes := []string{}
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(strings.TrimSpace(line), "otpauth://") {
+			entry.OTP = append(entry.OTP, strings.TrimSpace(line))
+			continue
+		}
+
+		key, value, ok := SplitField(line)
+		if !ok {
+			notes = append(notes, line)
+			continue
+		}
+
+		switch CanonicalField(key, aliases) {
+		case FieldUsername:
+			if entry.Username == "" {
+				entry.Username = value
+			}
+		case FieldURL:
+			entry.URLs = append(entry.URLs, value)
+		case FieldOTP:
+			entry.OTP = append(entry.OTP, value)
+		default:
+			entry.Fields = append(entry.Fields, Field{Key: key, Value: value})
+		}
+	}
+
+	entry.Notes = strings.Trim(strings.Join(notes, "\n"), "\n")
+
+	if entry.Username == "" {
+		entry.Username = usernameFromFile(file)
+	}
+
+	return entry
+}
+
+// usernameFromFile returns the username pass conventions take from the file name, which is the name without the extension
+func usernameFromFile(file string) string {
+	if file == "" {
+		return ""
+	}
+
+	username := path.Base(strings.ReplaceAll(file, "\\", "/"))
+	for _, extension := range []string{".gpg", ".age"} {
+		username = strings.TrimSuffix(username, extension)
+	}
+	return username
+}
+
+// Get returns the value of a requested field: "password", "notes", a well-known field
+// (or any of its aliases), or a custom field, where only the first URL, OTP or custom field is returned
+func (entry *Entry) Get(name string, aliases map[string][]string) (string, bool) {
+	field := strings.ToLower(name)
+	switch field {
+	case "password", "notes", FieldUsername, FieldURL, FieldOTP:
+	default:
+		field = CanonicalField(name, aliases)
+	}
+
+	switch field {
+	case "password":
+		return entry.Password, true
+	case "notes":
+		return entry.Notes, entry.Notes != ""
+	case FieldUsername:
+		return entry.Username, entry.Username != ""
+	case FieldURL:
+		return first(entry.URLs)
+	case FieldOTP:
+		return first(entry.OTP)
+	}
+
+	for _, field := range entry.Fields {
+		if strings.EqualFold(field.Key, name) {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+// Extract returns the values of the requested fields of the decrypted contents of a password entry,
+// with the same rules as Get. Unlike Parse, it reads the contents in place and only copies the requested values,
+// so that nothing else of the entry is left in memory once the caller wipes the contents.
+func Extract(contents []byte, file string, names []string, aliases map[string][]string) map[string]string {
+	lines := bytes.Split(contents, []byte("\n"))
+	for i := range lines {
+		lines[i] = bytes.TrimSuffix(lines[i], []byte("\r"))
+	}
+
+	extracted := make(map[string]string)
+	for _, name := range names {
+		if value, ok := extractField(lines, file, name, aliases); ok {
+			extracted[name] = value
+		}
+	}
+	return extracted
+}
+
+func extractField(lines [][]byte, file string, name string, aliases map[string][]string) (string, bool) {
+	field := strings.ToLower(name)
+	switch field {
+	case "password":
+		return string(lines[0]), true
+	case "notes", FieldUsername, FieldURL, FieldOTP:
+	default:
+		field = CanonicalField(name, aliases)
+	}
+
+	notes := [][]byte{}
+	for _, line := range lines[1:] {
+		if trimmed := bytes.TrimSpace(line); bytes.HasPrefix(trimmed, []byte("otpauth://")) {
+			if field == FieldOTP {
+				return string(trimmed), true
+			}
+			continue
+		}
+
+		key, value, ok := splitField(line)
+		if !ok {
+			notes = append(notes, line)
+			continue
+		}
+
+		canonical := canonicalField(key, aliases)
+		if field != "notes" && canonical == field && (field != "" || bytes.EqualFold(key, []byte(name))) {
+			return string(value), true
+		}
+	}
+
+	switch field {
+	case "notes":
+		// The joined notes are a copy of the contents as well, so they are wiped too
+		joined := bytes.Join(notes, []byte("\n"))
+		defer helpers.WipeBytes(joined)
+		trimmed := bytes.Trim(joined, "\n")
+		return string(trimmed), len(trimmed) > 0
+	case FieldUsername:
+		username := usernameFromFile(file)
+		return username, username != ""
+	}
+	return "", false
+}
+
+func first(values []string) (string, bool) {
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// SplitField splits a `key: value` line, lines like `https://example.com` are not considered fields
+func SplitField(line string) (string, string, bool) {
+	key, value, ok := splitField([]byte(line))
+	return string(key), string(value), ok
+}
+
+func splitField(line []byte) ([]byte, []byte, bool) {
+	index := bytes.IndexByte(line, ':')
+	if index <= 0 {
+		return nil, nil, false
+	}
+
+	key := bytes.TrimSpace(line[:index])
+	value := bytes.TrimSpace(line[index+1:])
+	if len(key) == 0 || bytes.HasPrefix(line[index+1:], []byte("//")) {
+		return nil, nil, false
+	}
+
+	return key, value, true
+}
+
+// CanonicalField returns the canonical name of a well-known field the key is an alias of,
+// or an empty string for custom fields
+func CanonicalField(key string, aliases map[string][]string) string {
+	return canonicalField([]byte(key), aliases)
+}
+
+func canonicalField(key []byte, aliases map[string][]string) string {
+	for _, field := range []string{FieldUsername, FieldURL, FieldOTP} {
+		fieldAliases, ok := aliases[field]
+		if !ok {
+			fieldAliases = DefaultAliases[field]
+		}
+		for _, alias := range fieldAliases {
+			if bytes.EqualFold([]byte(alias), key) {
+				return field
+			}
+		}
+	}
+	return ""
+}
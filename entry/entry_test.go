@@ -0,0 +1,82 @@
+package entry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Parse_PassConventions(t *testing.T) {
+	// Arrange
+	contents := "s3cr3t: pass\r\n" +
+		"Login: alice\r\n" +
+		"url: https://example.com/login\r\n" +
+		"https://example.com\r\n" +
+		"otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP\r\n" +
+		"pin: 1234\r\n" +
+		"\r\n" +
+		"Recovery codes are in the safe.\r\n"
+
+	expected := &Entry{
+		Password: "s3cr3t: pass",
+		Username: "alice",
+		URLs:     []string{"https://example.com/login"},
+		OTP:      []string{"otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP"},
+		Fields:   []Field{{Key: "pin", Value: "1234"}},
+		Notes:    "https://example.com\n\nRecovery codes are in the safe.",
+	}
+
+	// Act
+	actual := Parse(contents, "work/example.com.gpg", nil)
+
+	// Assert
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("The entry was parsed incorrectly.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
+
+func Test_Parse_UsernameFromFileNameAndCustomAliases(t *testing.T) {
+	// Arrange
+	contents := "hunter2\nemail: bob@example.com\nuser: not-an-alias-anymore\n"
+	aliases := map[string][]string{FieldUsername: {"email"}}
+
+	// Act
+	actual := Parse(contents, "personal/bob.gpg", aliases)
+
+	// Assert
+	if actual.Username != "bob@example.com" {
+		t.Fatalf("The username '%v' was not taken from the aliased field", actual.Username)
+	}
+
+	actual = Parse("hunter2\n", "personal/bob.gpg", aliases)
+	if actual.Username != "bob" {
+		t.Fatalf("The username '%v' was not taken from the file name", actual.Username)
+	}
+}
+
+func Test_Extract_SameValuesAsGet(t *testing.T) {
+	// Arrange
+	contents := "s3cr3t: pass\r\n" +
+		"url: https://example.com/login\r\n" +
+		"https://example.com\r\n" +
+		"otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP\r\n" +
+		"PIN: 1234\r\n" +
+		"\r\n" +
+		"Recovery codes are in the safe.\r\n"
+	names := []string{"password", "username", "login", "url", "website", "otp", "pin", "notes", "missing"}
+	parsed := Parse(contents, "work/alice.gpg", nil)
+
+	expected := map[string]string{}
+	for _, name := range names {
+		if value, ok := parsed.Get(name, nil); ok {
+			expected[name] = value
+		}
+	}
+
+	// Act
+	actual := Extract([]byte(contents), "work/alice.gpg", names, nil)
+
+	// Assert
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("The fields were extracted incorrectly.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
@@ -0,0 +1,178 @@
+package entry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Change a change of a single field of a password entry: "password", a well-known field
+// (or any of its aliases), or a custom `key: value` field
+type Change struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Remove bool   `json:"remove"`
+}
+
+// line a line of the decrypted contents, together with its original line ending
+type line struct {
+	text   string
+	ending string
+}
+
+// Update applies the changes to the decrypted contents of a password entry. Only the changed lines are touched,
+// so the order of the lines, unknown lines and the line endings are all preserved. A field is changed
+// on the first line it is found on, same as Get, and new fields are added after the last `key: value` line.
+func Update(contents string, changes []Change, aliases map[string][]string) (string, error) {
+	lines := splitLines(contents)
+	for _, change := range changes {
+		if err := validateChange(change); err != nil {
+			return "", err
+		}
+		change.Field = strings.TrimSpace(change.Field)
+
+		if strings.EqualFold(change.Field, "password") {
+			if len(lines) == 0 {
+				lines = append(lines, line{ending: defaultEnding(lines)})
+			}
+			lines[0].text = change.Value
+			continue
+		}
+
+		index := findField(lines, change.Field, aliases)
+		switch {
+		case index < 0 && change.Remove:
+			// Nothing to remove
+		case index < 0:
+			lines = insertField(lines, change.Field+": "+change.Value)
+		case change.Remove:
+			if lines[index].ending == "" {
+				lines[index-1].ending = ""
+			}
+			lines = append(lines[:index], lines[index+1:]...)
+		default:
+			lines[index].text = replaceFieldValue(lines[index].text, change.Value)
+		}
+	}
+
+	var updated strings.Builder
+	for _, l := range lines {
+		updated.WriteString(l.text)
+		updated.WriteString(l.ending)
+	}
+	return updated.String(), nil
+}
+
+func validateChange(change Change) error {
+	field := strings.TrimSpace(change.Field)
+	switch {
+	case field == "":
+		return fmt.Errorf("The field name is missing")
+	case strings.ContainsAny(change.Field, ":\r\n"):
+		return fmt.Errorf("The field name '%s' must not contain colons or line breaks", change.Field)
+	case strings.ContainsAny(change.Value, "\r\n"):
+		return fmt.Errorf("The value of the field '%s' must not contain line breaks", change.Field)
+	case strings.EqualFold(field, "notes"):
+		return fmt.Errorf("The notes can not be changed field by field")
+	case strings.EqualFold(field, "password") && change.Remove:
+		return fmt.Errorf("The password can not be removed")
+	}
+	return nil
+}
+
+func splitLines(contents string) []line {
+	lines := []line{}
+	for contents != "" {
+		index := strings.Index(contents, "\n")
+		if index < 0 {
+			lines = append(lines, line{text: contents})
+			break
+		}
+
+		l := line{text: contents[:index], ending: "\n"}
+		if strings.HasSuffix(l.text, "\r") {
+			l.text = strings.TrimSuffix(l.text, "\r")
+			l.ending = "\r\n"
+		}
+		lines = append(lines, l)
+		contents = contents[index+1:]
+	}
+	return lines
+}
+
+// defaultEnding returns the line ending used by the entry, for the lines added to it
+func defaultEnding(lines []line) string {
+	for _, l := range lines {
+		if l.ending != "" {
+			return l.ending
+		}
+	}
+	return "\n"
+}
+
+// findField returns the index of the first line of a field, or -1 if the entry does not have it
+func findField(lines []line, field string, aliases map[string][]string) int {
+	canonical := strings.ToLower(field)
+	switch canonical {
+	case FieldUsername, FieldURL, FieldOTP:
+	default:
+		canonical = CanonicalField(field, aliases)
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if canonical == FieldOTP && strings.HasPrefix(strings.TrimSpace(lines[i].text), "otpauth://") {
+			return i
+		}
+
+		key, _, ok := SplitField(lines[i].text)
+		if !ok {
+			continue
+		}
+		if canonical != "" && CanonicalField(key, aliases) == canonical {
+			return i
+		}
+		if canonical == "" && strings.EqualFold(key, field) {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertField adds a new field line after the last `key: value` line, or right after the password
+func insertField(lines []line, text string) []line {
+	ending := defaultEnding(lines)
+	if len(lines) == 0 {
+		lines = append(lines, line{ending: ending})
+	}
+
+	index := 1
+	for i := 1; i < len(lines); i++ {
+		if _, _, ok := SplitField(lines[i].text); ok {
+			index = i + 1
+		}
+	}
+
+	// A missing line ending at the end of the entry stays missing
+	added := line{text: text, ending: ending}
+	if lines[index-1].ending == "" {
+		lines[index-1].ending = ending
+		added.ending = ""
+	}
+
+	lines = append(lines, line{})
+	copy(lines[index+1:], lines[index:])
+	lines[index] = added
+	return lines
+}
+
+// replaceFieldValue replaces the value of a `key: value` line, keeping the key and the spacing after the colon.
+// A bare `otpauth://` line is replaced as a whole.
+func replaceFieldValue(text string, value string) string {
+	if strings.HasPrefix(strings.TrimSpace(text), "otpauth://") {
+		return value
+	}
+
+	colon := strings.Index(text, ":")
+	rest := text[colon+1:]
+	spacing := rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+	return text[:colon+1] + spacing + value
+}
@@ -0,0 +1,90 @@
+package entry
+
+import (
+	"testing"
+)
+
+func Test_Update_PreservesOtherLines(t *testing.T) {
+	// Arrange
+	contents := "old\r\n" +
+		"Login:alice\r\n" +
+		"https://example.com\r\n" +
+		"otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP\r\n" +
+		"pin: 1234\r\n" +
+		"\r\n" +
+		"Recovery codes are in the safe.\r\n"
+	changes := []Change{
+		{Field: "password", Value: "new"},
+		{Field: "username", Value: "bob"},
+		{Field: "PIN", Value: "4321"},
+		{Field: "otp", Remove: true},
+	}
+
+	// Act
+	actual, err := Update(contents, changes, nil)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error updating: %v", err)
+	}
+
+	expected := "new\r\n" +
+		"Login:bob\r\n" +
+		"https://example.com\r\n" +
+		"pin: 4321\r\n" +
+		"\r\n" +
+		"Recovery codes are in the safe.\r\n"
+	if actual != expected {
+		t.Fatalf("The entry was updated incorrectly.\nExpected: %q\nActual:   %q", expected, actual)
+	}
+}
+
+func Test_Update_AddsFieldAfterLastField(t *testing.T) {
+	// Arrange
+	contents := "hunter2\nlogin: alice\nSome notes"
+	changes := []Change{{Field: "email", Value: "alice@example.com"}}
+
+	// Act
+	actual, err := Update(contents, changes, nil)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error updating: %v", err)
+	}
+
+	expected := "hunter2\nlogin: alice\nemail: alice@example.com\nSome notes"
+	if actual != expected {
+		t.Fatalf("The entry was updated incorrectly.\nExpected: %q\nActual:   %q", expected, actual)
+	}
+}
+
+func Test_Update_AddsFieldWithoutTrailingNewline(t *testing.T) {
+	// Arrange
+	contents := "hunter2"
+	changes := []Change{{Field: "login", Value: "alice"}}
+
+	// Act
+	actual, err := Update(contents, changes, nil)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error updating: %v", err)
+	}
+
+	if actual != "hunter2\nlogin: alice" {
+		t.Fatalf("The entry was updated incorrectly: %q", actual)
+	}
+}
+
+func Test_Update_RejectsLineBreaks(t *testing.T) {
+	// Arrange
+	changes := []Change{{Field: "login", Value: "alice\nurl: https://evil.example.com"}}
+
+	// Act
+	_, err := Update("hunter2\n", changes, nil)
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected an error for a value with a line break, but didn't get it")
+	}
+}
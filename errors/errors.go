@@ -33,6 +33,27 @@ const (
 	CodeUnableToDeletePasswordFile                            Code = 30
 	CodeUnableToDetermineIsDirectoryEmpty                     Code = 31
 	CodeUnableToDeleteEmptyDirectory                          Code = 32
+	CodeUnableToWatchPasswordStore                            Code = 33
+	CodeInvalidListFilter                                     Code = 34
+	CodeNoOtpInPasswordFile                                   Code = 35
+	CodeInvalidOtpParameters                                  Code = 36
+	CodeInvalidEncryptionBackend                              Code = 37
+	CodeNoSecretKey                                           Code = 38
+	CodeBadPassphrase                                         Code = 39
+	CodePinentryCancelled                                     Code = 40
+	CodeSmartcardNotPresent                                   Code = 41
+	CodeCorruptedPasswordFile                                 Code = 42
+	CodeUnusablePublicKey                                     Code = 43
+	CodeUnverifiedRecipientsFile                              Code = 44
+	CodeInvalidContentsEncoding                               Code = 45
+	CodePasswordFileConflict                                  Code = 46
+	CodeUnableToCommitChange                                  Code = 47
+	CodeUnableToSyncPasswordStore                             Code = 48
+	CodeUnableToMovePasswordFile                              Code = 49
+	CodeUnableToCopyPasswordFile                              Code = 50
+	CodeInvalidPasswordPolicy                                 Code = 51
+	CodeUnableToAccessPasswordHistory                         Code = 52
+	CodeInvalidEntryChanges                                   Code = 53
 )
 
 // Field extra field in the error response params
@@ -50,6 +71,8 @@ const (
 	FieldFile      Field = "file"
 	FieldDirectory Field = "directory"
 	FieldGpgPath   Field = "gpgPath"
+	FieldKeyIDs    Field = "keyIds"
+	FieldVersion   Field = "version"
 )
 
 // ExitWithCode exit with error code
@@ -0,0 +1,209 @@
+package generate
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// Types of generated secrets
+const (
+	TypePassword   = "password"
+	TypePassphrase = "passphrase"
+)
+
+// Character classes a generated password can be made of
+const (
+	ClassLower   = "lower"
+	ClassUpper   = "upper"
+	ClassDigits  = "digits"
+	ClassSymbols = "symbols"
+)
+
+var classCharacters = map[string]string{
+	ClassLower:   "abcdefghijklmnopqrstuvwxyz",
+	ClassUpper:   "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	ClassDigits:  "0123456789",
+	ClassSymbols: "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+}
+
+// Characters that are easily mistaken for each other when read or typed by hand
+const lookalikes = "Il1|O0o"
+
+// Limits that keep a policy from making the host app generate huge amounts of data
+const (
+	maxLength   = 1024
+	maxWords    = 64
+	maxAttempts = 1000
+)
+
+// EFF large wordlist for passphrases (https://www.eff.org/dice), licensed under CC BY 3.0 US.
+// Each line is a five-dice roll and a word, separated by a tab.
+//
+//go:embed eff_large_wordlist.txt
+var effLargeWordlist string
+
+var wordlist = sync.OnceValue(func() []string {
+	words := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(effLargeWordlist), "\n") {
+		if _, word, ok := strings.Cut(line, "\t"); ok {
+			words = append(words, strings.TrimSpace(word))
+		}
+	}
+	return words
+})
+
+// Policy rules for generating a password or a passphrase
+type Policy struct {
+	Type              string   `json:"type"`
+	Length            int      `json:"length"`
+	Classes           []string `json:"classes"`
+	Required          []string `json:"required"`
+	ExcludeLookalikes bool     `json:"excludeLookalikes"`
+	Words             int      `json:"words"`
+	Separator         string   `json:"separator"`
+}
+
+// DefaultPolicy returns the policy used for anything that is not configured,
+// the default length is the same as for `pass generate`
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Type:      TypePassword,
+		Length:    25,
+		Classes:   []string{ClassLower, ClassUpper, ClassDigits, ClassSymbols},
+		Required:  []string{},
+		Words:     6,
+		Separator: "-",
+	}
+}
+
+// Validate checks that a secret can be generated according to the policy
+func (policy *Policy) Validate() error {
+	switch policy.Type {
+	case TypePassword:
+		if policy.Length < 1 || policy.Length > maxLength {
+			return fmt.Errorf("The password length must be between 1 and %d", maxLength)
+		}
+		if len(policy.Classes) == 0 {
+			return fmt.Errorf("At least one character class is required")
+		}
+		for _, class := range policy.Classes {
+			if _, ok := classCharacters[class]; !ok {
+				return fmt.Errorf("Unknown character class '%s'", class)
+			}
+		}
+		for _, class := range policy.Required {
+			if !contains(policy.Classes, class) {
+				return fmt.Errorf("The required character class '%s' is not one of the allowed classes", class)
+			}
+		}
+		if len(policy.Required) > policy.Length {
+			return fmt.Errorf("The password is too short to contain all required character classes")
+		}
+	case TypePassphrase:
+		if policy.Words < 1 || policy.Words > maxWords {
+			return fmt.Errorf("The number of words must be between 1 and %d", maxWords)
+		}
+	default:
+		return fmt.Errorf("Unknown type '%s'", policy.Type)
+	}
+	return nil
+}
+
+// Generate generates a password or a passphrase according to the policy, using crypto/rand
+func Generate(policy *Policy) (string, error) {
+	if err := policy.Validate(); err != nil {
+		return "", err
+	}
+
+	if policy.Type == TypePassphrase {
+		return generatePassphrase(policy)
+	}
+	return generatePassword(policy)
+}
+
+// generatePassword draws every character uniformly from all allowed classes. Passwords that miss
+// a required class are discarded as a whole, which keeps all valid passwords equally likely.
+func generatePassword(policy *Policy) (string, error) {
+	alphabet := []rune{}
+	for _, class := range policy.Classes {
+		alphabet = append(alphabet, classRunes(class, policy.ExcludeLookalikes)...)
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		password := make([]rune, policy.Length)
+		for i := range password {
+			index, err := randomIndex(len(alphabet))
+			if err != nil {
+				return "", err
+			}
+			password[i] = alphabet[index]
+		}
+
+		if containsRequiredClasses(password, policy) {
+			return string(password), nil
+		}
+	}
+	return "", fmt.Errorf("Unable to generate a password containing all required character classes")
+}
+
+func generatePassphrase(policy *Policy) (string, error) {
+	words := wordlist()
+	passphrase := make([]string, policy.Words)
+	for i := range passphrase {
+		index, err := randomIndex(len(words))
+		if err != nil {
+			return "", err
+		}
+		passphrase[i] = words[index]
+	}
+	return strings.Join(passphrase, policy.Separator), nil
+}
+
+func classRunes(class string, excludeLookalikes bool) []rune {
+	runes := []rune{}
+	for _, r := range classCharacters[class] {
+		if excludeLookalikes && strings.ContainsRune(lookalikes, r) {
+			continue
+		}
+		runes = append(runes, r)
+	}
+	return runes
+}
+
+func containsRequiredClasses(password []rune, policy *Policy) bool {
+	for _, class := range policy.Required {
+		found := false
+		for _, r := range password {
+			if strings.ContainsRune(classCharacters[class], r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// randomIndex returns a uniformly distributed random number in [0, n)
+func randomIndex(n int) (int, error) {
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(index.Int64()), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
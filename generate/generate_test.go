@@ -0,0 +1,94 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Generate_DefaultPolicy(t *testing.T) {
+	// Arrange
+	policy := DefaultPolicy()
+
+	// Act
+	password, err := Generate(policy)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+
+	if len(password) != 25 {
+		t.Fatalf("Expected a password of 25 characters, but got '%v'", password)
+	}
+}
+
+func Test_Generate_AlphanumericWithRequiredClasses(t *testing.T) {
+	// Arrange
+	policy := &Policy{
+		Type:              TypePassword,
+		Length:            4,
+		Classes:           []string{ClassLower, ClassUpper, ClassDigits},
+		Required:          []string{ClassLower, ClassUpper, ClassDigits},
+		ExcludeLookalikes: true,
+	}
+
+	for i := 0; i < 100; i++ {
+		// Act
+		password, err := Generate(policy)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Error generating: %v", err)
+		}
+
+		if len(password) != 4 || strings.ContainsAny(password, lookalikes+classCharacters[ClassSymbols]) {
+			t.Fatalf("The password '%v' does not match the policy", password)
+		}
+
+		for _, class := range policy.Required {
+			if !strings.ContainsAny(password, classCharacters[class]) {
+				t.Fatalf("The password '%v' does not contain the required class '%v'", password, class)
+			}
+		}
+	}
+}
+
+func Test_Generate_Passphrase(t *testing.T) {
+	// Arrange
+	policy := DefaultPolicy()
+	policy.Type = TypePassphrase
+	policy.Words = 5
+	policy.Separator = " "
+
+	// Act
+	passphrase, err := Generate(policy)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+
+	words := strings.Split(passphrase, " ")
+	if len(words) != 5 {
+		t.Fatalf("Expected a passphrase of 5 words, but got '%v'", passphrase)
+	}
+
+	if len(wordlist()) != 7776 {
+		t.Fatalf("Expected the EFF large wordlist of 7776 words, but got %v words", len(wordlist()))
+	}
+}
+
+func Test_Validate_RequiredClassNotAllowed(t *testing.T) {
+	// Arrange
+	policy := DefaultPolicy()
+	policy.Classes = []string{ClassLower, ClassDigits}
+	policy.Required = []string{ClassSymbols}
+
+	// Act
+	err := policy.Validate()
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected an error for a required class that is not allowed, but didn't get it")
+	}
+}
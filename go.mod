@@ -5,8 +5,16 @@ go 1.24.0
 toolchain go1.24.6
 
 require (
-	github.com/mattn/go-zglob v0.0.6
+	filippo.io/age v1.2.1
+	github.com/ProtonMail/go-crypto v1.3.0
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/rifflock/lfshook v0.0.0-20180920164130-b9218ef580f5
 	github.com/sirupsen/logrus v1.9.3
 	golang.org/x/sys v0.36.0
 )
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/cloudflare/circl v1.6.0 // indirect
+	golang.org/x/crypto v0.33.0 // indirect
+)
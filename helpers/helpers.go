@@ -1,13 +1,13 @@
 package helpers
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
@@ -32,72 +32,87 @@ func ValidateGpgBinary(gpgPath string) error {
 	return exec.Command(gpgPath, "--version").Run()
 }
 
-func GpgDecryptFile(filePath string, gpgPath string) (string, error) {
-	passwordFile, err := os.Open(filePath)
-	if err != nil {
-		return "", err
-	}
-
-	var stdout, stderr bytes.Buffer
-	gpgOptions := []string{"--decrypt", "--yes", "--quiet", "--batch", "-"}
-
-	cmd := exec.Command(gpgPath, gpgOptions...)
-	cmd.Stdin = passwordFile
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("Error: %s, Stderr: %s", err.Error(), stderr.String())
-	}
+// Names of the files listing the recipients of the password files in a directory and its subdirectories
+const (
+	GpgRecipientsFile = ".gpg-id"
+	AgeRecipientsFile = ".age-recipients"
+)
 
-	return stdout.String(), nil
+func DetectGpgRecipients(filePath string) ([]string, error) {
+	return DetectRecipients(filePath, GpgRecipientsFile)
 }
 
-func GpgEncryptFile(filePath string, contents string, recipients []string, gpgPath string) error {
-	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+// DetectRecipients reads the recipients from the nearest recipients file with the given name,
+// looking in the directory of the password file and then in its parent directories
+func DetectRecipients(filePath string, recipientsFile string) ([]string, error) {
+	recipientsPath, err := FindRecipientsFile(filePath, recipientsFile)
 	if err != nil {
-		return fmt.Errorf("Unable to create directory structure: %s", err.Error())
-	}
-
-	var stdout, stderr bytes.Buffer
-	gpgOptions := []string{"--encrypt", "--yes", "--quiet", "--batch", "--output", filePath}
-	for _, recipient := range recipients {
-		gpgOptions = append(gpgOptions, "--recipient", recipient)
+		return nil, err
 	}
 
-	cmd := exec.Command(gpgPath, gpgOptions...)
-	cmd.Stdin = strings.NewReader(contents)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err = cmd.Run(); err != nil {
-		return fmt.Errorf("Error: %s, Stderr: %s", err.Error(), stderr.String())
+	recipients, err := ReadRecipients(filepath.Dir(recipientsPath), recipientsFile)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open `%s` file: %s", recipientsFile, err.Error())
 	}
-
-	return nil
+	return recipients, nil
 }
 
-func DetectGpgRecipients(filePath string) ([]string, error) {
+// FindRecipientsFile finds the path of the nearest recipients file with the given name,
+// looking in the directory of the password file and then in its parent directories
+func FindRecipientsFile(filePath string, recipientsFile string) (string, error) {
 	dir := filepath.Dir(filePath)
 	for {
-		file, err := ioutil.ReadFile(filepath.Join(dir, ".gpg-id"))
+		recipientsPath := filepath.Join(dir, recipientsFile)
+		_, err := os.Stat(recipientsPath)
 		if err == nil {
-			return strings.Split(strings.ReplaceAll(strings.TrimSpace(string(file)), "\r\n", "\n"), "\n"), nil
+			return recipientsPath, nil
 		}
 
 		if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("Unable to open `.gpg-id` file: %s", err.Error())
+			return "", fmt.Errorf("Unable to open `%s` file: %s", recipientsFile, err.Error())
 		}
 
 		parentDir := filepath.Dir(dir)
 		if parentDir == dir {
-			return nil, fmt.Errorf("Unable to find '.gpg-id' file")
+			return "", fmt.Errorf("Unable to find '%s' file: %w", recipientsFile, os.ErrNotExist)
 		}
 
 		dir = parentDir
 	}
 }
 
+// ReadGpgRecipients reads the recipients from the `.gpg-id` file located directly in the given directory
+func ReadGpgRecipients(dir string) ([]string, error) {
+	return ReadRecipients(dir, GpgRecipientsFile)
+}
+
+// ReadRecipients reads the recipients from the recipients file located directly in the given directory,
+// blank lines and `#` comments are ignored
+func ReadRecipients(dir string, recipientsFile string) ([]string, error) {
+	file, err := ioutil.ReadFile(filepath.Join(dir, recipientsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := []string{}
+	for _, line := range strings.Split(string(file), "\n") {
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			recipients = append(recipients, line)
+		}
+	}
+	return recipients, nil
+}
+
+// WipeBytes overwrites secret data with zeros, so that it does not linger in memory
+func WipeBytes(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
+
 func IsDirectoryEmpty(dirPath string) (bool, error) {
 	f, err := os.Open(dirPath)
 	if err != nil {
@@ -112,3 +127,62 @@ func IsDirectoryEmpty(dirPath string) (bool, error) {
 
 	return false, err
 }
+
+// WriteFileAtomically replaces the contents of a file in a crash-safe way: the data is written
+// to a temporary file in the same directory, flushed to disk and then renamed over the original file,
+// so the file either has the old or the new contents, but is never truncated.
+// The permissions of an existing file are kept, new files are only readable by the owner.
+func WriteFileAtomically(filePath string, data []byte) error {
+	// Replace the target of a symlinked file, not the symlink itself
+	if target, err := filepath.EvalSymlinks(filePath); err == nil {
+		filePath = target
+	}
+
+	perm := os.FileMode(0600)
+	if fi, err := os.Stat(filePath); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	// The temporary file is hidden and has no password file extension, so it is never listed
+	dir, name := filepath.Split(filePath)
+	file, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		err = file.Chmod(perm)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, filePath)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return syncDirectory(filepath.Dir(filePath))
+}
+
+// syncDirectory flushes a directory entry change (e.g. a rename) to disk
+func syncDirectory(dirPath string) error {
+	// Directories can not be opened for syncing on Windows, where renames are journaled by NTFS anyway
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
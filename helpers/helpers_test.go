@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_WriteFileAtomically_KeepsPermissions(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "site.gpg")
+	if err := os.WriteFile(filePath, []byte("old"), 0640); err != nil {
+		t.Fatalf("Unable to write the test file: %v", err)
+	}
+	if err := os.Chmod(filePath, 0640); err != nil {
+		t.Fatalf("Unable to change the permissions of the test file: %v", err)
+	}
+
+	// Act
+	err := WriteFileAtomically(filePath, []byte("new"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error writing the file: %v", err)
+	}
+
+	contents, err := os.ReadFile(filePath)
+	if err != nil || !bytes.Equal(contents, []byte("new")) {
+		t.Fatalf("Expected the new contents, but got '%s' (%v)", contents, err)
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil || fi.Mode().Perm() != 0640 {
+		t.Fatalf("Expected the permissions to be kept, but got %v (%v)", fi.Mode().Perm(), err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected no temporary files to be left behind, but got %v (%v)", entries, err)
+	}
+}
+
+func Test_WriteFileAtomically_ReplacesSymlinkTarget(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	targetPath := filepath.Join(dir, "target.gpg")
+	linkPath := filepath.Join(dir, "link.gpg")
+	if err := os.WriteFile(targetPath, []byte("old"), 0600); err != nil {
+		t.Fatalf("Unable to write the test file: %v", err)
+	}
+	if err := os.Symlink(targetPath, linkPath); err != nil {
+		t.Skipf("Unable to create a symlink: %v", err)
+	}
+
+	// Act
+	err := WriteFileAtomically(linkPath, []byte("new"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error writing the file: %v", err)
+	}
+
+	if fi, err := os.Lstat(linkPath); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("Expected the symlink to be kept (%v)", err)
+	}
+
+	contents, err := os.ReadFile(targetPath)
+	if err != nil || !bytes.Equal(contents, []byte("new")) {
+		t.Fatalf("Expected the new contents in the symlink target, but got '%s' (%v)", contents, err)
+	}
+}
@@ -24,7 +24,8 @@ func main() {
 		os.Exit(0)
 	}
 
-	openbsd.Pledge("stdio rpath proc exec getpw unix tty")
+	// fattr is needed to keep the permissions of password files that are replaced atomically
+	openbsd.Pledge("stdio rpath wpath cpath fattr proc exec getpw unix tty")
 
 	log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
 	if isVerbose {
@@ -0,0 +1,178 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of one-time passwords
+const (
+	TypeTOTP = "totp"
+	TypeHOTP = "hotp"
+)
+
+// Characters used by Steam Guard codes instead of decimal digits
+const steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+const steamDigits = 5
+
+var counterParam = regexp.MustCompile(`([?&])counter=[0-9]*`)
+
+// Key parameters of a one-time password generator
+type Key struct {
+	Type      string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	Period    int
+	Counter   uint64
+	Steam     bool
+}
+
+// Parse parses an `otpauth://` URI as used by pass-otp, or a bare base32 secret of a `totp:` field
+func Parse(value string) (*Key, error) {
+	value = strings.TrimSpace(value)
+	key := &Key{
+		Type:      TypeTOTP,
+		Algorithm: "SHA1",
+		Digits:    6,
+		Period:    30,
+	}
+
+	if !strings.HasPrefix(value, "otpauth://") {
+		secret, err := decodeSecret(value)
+		if err != nil {
+			return nil, err
+		}
+		key.Secret = secret
+		return key, nil
+	}
+
+	uri, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Type = strings.ToLower(uri.Host)
+	if key.Type != TypeTOTP && key.Type != TypeHOTP {
+		return nil, fmt.Errorf("Unsupported OTP type '%v'", uri.Host)
+	}
+
+	query := uri.Query()
+	if key.Secret, err = decodeSecret(query.Get("secret")); err != nil {
+		return nil, err
+	}
+
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		key.Algorithm = strings.ToUpper(algorithm)
+		if key.Algorithm != "SHA1" && key.Algorithm != "SHA256" && key.Algorithm != "SHA512" {
+			return nil, fmt.Errorf("Unsupported OTP algorithm '%v'", algorithm)
+		}
+	}
+
+	if digits := query.Get("digits"); digits != "" {
+		if key.Digits, err = strconv.Atoi(digits); err != nil || key.Digits < 6 || key.Digits > 8 {
+			return nil, fmt.Errorf("Unsupported number of OTP digits '%v'", digits)
+		}
+	}
+
+	if period := query.Get("period"); period != "" {
+		if key.Period, err = strconv.Atoi(period); err != nil || key.Period <= 0 {
+			return nil, fmt.Errorf("Invalid OTP period '%v'", period)
+		}
+	}
+
+	if key.Type == TypeHOTP {
+		if key.Counter, err = strconv.ParseUint(query.Get("counter"), 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid HOTP counter '%v'", query.Get("counter"))
+		}
+	}
+
+	if strings.EqualFold(query.Get("encoder"), "steam") {
+		key.Steam = true
+		key.Digits = steamDigits
+	}
+
+	return key, nil
+}
+
+// TOTP generates the time-based code valid at the given moment,
+// and returns it together with the number of seconds it remains valid
+func (key *Key) TOTP(now time.Time) (string, int) {
+	period := int64(key.Period)
+	seconds := now.Unix()
+	return key.Code(uint64(seconds / period)), int(period - seconds%period)
+}
+
+// Code generates the code for the given counter value as defined in RFC 4226
+func (key *Key) Code(counter uint64) string {
+	var newHash func() hash.Hash
+	switch key.Algorithm {
+	case "SHA256":
+		newHash = sha256.New
+	case "SHA512":
+		newHash = sha512.New
+	default:
+		newHash = sha1.New
+	}
+
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, counter)
+	mac := hmac.New(newHash, key.Secret)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	if key.Steam {
+		code := make([]byte, steamDigits)
+		for i := range code {
+			code[i] = steamAlphabet[value%uint32(len(steamAlphabet))]
+			value /= uint32(len(steamAlphabet))
+		}
+		return string(code)
+	}
+
+	modulo := uint32(1)
+	for i := 0; i < key.Digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", key.Digits, value%modulo)
+}
+
+// SetCounter replaces the counter of an `otpauth://` URI, keeping the rest of the URI intact
+func SetCounter(uri string, counter uint64) string {
+	value := strconv.FormatUint(counter, 10)
+	if counterParam.MatchString(uri) {
+		return counterParam.ReplaceAllString(uri, "${1}counter="+value)
+	}
+	if strings.Contains(uri, "?") {
+		return uri + "&counter=" + value
+	}
+	return uri + "?counter=" + value
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+	secret = strings.TrimRight(secret, "=")
+	if secret == "" {
+		return nil, fmt.Errorf("The OTP secret is missing")
+	}
+
+	decoded, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid OTP secret: %s", err.Error())
+	}
+	return decoded, nil
+}
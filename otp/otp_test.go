@@ -0,0 +1,85 @@
+package otp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// Test vectors from RFC 6238, the secrets are the ASCII seeds from the RFC
+func Test_TOTP_RFC6238(t *testing.T) {
+	seeds := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	expected := map[string]string{
+		"SHA1":   "94287082",
+		"SHA256": "46119246",
+		"SHA512": "90693936",
+	}
+
+	for algorithm, seed := range seeds {
+		// Arrange
+		secret := base32.StdEncoding.EncodeToString([]byte(seed))
+		key, err := Parse("otpauth://totp/Test?secret=" + secret + "&algorithm=" + algorithm + "&digits=8")
+		if err != nil {
+			t.Fatalf("Error parsing the %v URI: %v", algorithm, err)
+		}
+
+		// Act
+		code, remaining := key.TOTP(time.Unix(59, 0))
+
+		// Assert
+		if code != expected[algorithm] || remaining != 1 {
+			t.Fatalf("Expected %v code '%v' valid for 1s, but got '%v' valid for %vs", algorithm, expected[algorithm], code, remaining)
+		}
+	}
+}
+
+// Test vectors from RFC 4226
+func Test_Code_HOTP_RFC4226(t *testing.T) {
+	// Arrange
+	key, err := Parse("otpauth://hotp/Test?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=1")
+	if err != nil {
+		t.Fatalf("Error parsing the URI: %v", err)
+	}
+
+	// Act
+	code := key.Code(key.Counter)
+
+	// Assert
+	if code != "287082" {
+		t.Fatalf("Expected the code '287082', but got '%v'", code)
+	}
+}
+
+func Test_Parse_BareSecretAndSteam(t *testing.T) {
+	// Act
+	bare, err := Parse("jbsw y3dp ehpk 3pxp")
+	if err != nil {
+		t.Fatalf("Error parsing a bare secret: %v", err)
+	}
+	steam, err := Parse("otpauth://totp/Steam:alice?secret=JBSWY3DPEHPK3PXP&encoder=steam")
+	if err != nil {
+		t.Fatalf("Error parsing a Steam URI: %v", err)
+	}
+
+	// Assert
+	if bare.Type != TypeTOTP || bare.Digits != 6 || bare.Period != 30 {
+		t.Fatalf("Unexpected defaults for a bare secret: %+v", bare)
+	}
+	if code := steam.Code(1); len(code) != 5 {
+		t.Fatalf("Expected a 5 character Steam code, but got '%v'", code)
+	}
+}
+
+func Test_SetCounter_KeepsOtherParameters(t *testing.T) {
+	// Act
+	actual := SetCounter("otpauth://hotp/Test?secret=ABC&counter=41&digits=8", 42)
+
+	// Assert
+	if actual != "otpauth://hotp/Test?secret=ABC&counter=42&digits=8" {
+		t.Fatalf("The counter was replaced incorrectly: %v", actual)
+	}
+}
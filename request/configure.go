@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/browserpass/browserpass-native/v3/backend"
 	"github.com/browserpass/browserpass-native/v3/errors"
 	"github.com/browserpass/browserpass-native/v3/helpers"
 	"github.com/browserpass/browserpass-native/v3/response"
@@ -58,6 +59,14 @@ func configure(request *request) {
 		}
 
 		store.Path = normalizedStorePath
+		store.backendName = getStoreBackendName(store)
+
+		// The store format is detected here, unless the backend is configured explicitly.
+		// The gpg binary is validated lazily as before, other backends are validated right away.
+		responseData.StoreBackends[store.ID] = store.backendName
+		if responseData.StoreBackends[store.ID] != backend.NameGpg {
+			getBackend(request, store, "configure")
+		}
 
 		responseData.StoreSettings[store.ID], err = readDefaultSettings(store.Path)
 		if err == nil {
@@ -117,6 +126,8 @@ func configure(request *request) {
 			}
 		}
 
+		responseData.DefaultStore.Backend = detectStoreBackend(store{Path: responseData.DefaultStore.Path})
+
 		responseData.DefaultStore.Settings, err = readDefaultSettings(responseData.DefaultStore.Path)
 		if err == nil {
 			var storeSettings StoreSettings
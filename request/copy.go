@@ -0,0 +1,145 @@
+package request
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/backend"
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/helpers"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+func copyPasswordFiles(request *request) {
+	responseData := response.MakeCopyResponse()
+
+	store := getRequestedStore(request, request.StoreID, "copy")
+	crypto := getBackend(request, store, "copy")
+
+	destinationStore, destinationCrypto := store, crypto
+	if request.DestinationStoreID != "" && request.DestinationStoreID != store.ID {
+		destinationStore = getRequestedStore(request, request.DestinationStoreID, "copy")
+		destinationCrypto = getBackend(request, destinationStore, "copy")
+	}
+
+	source := strings.TrimSuffix(filepath.ToSlash(request.File), "/")
+	destination := strings.TrimSuffix(filepath.ToSlash(request.Destination), "/")
+	if source == "" || destination == "" ||
+		(destinationStore.ID == store.ID && (source == destination || strings.HasPrefix(destination, source+"/"))) {
+		log.Errorf("Unable to copy '%v' to '%v' in the password store '%+v'", source, destination, store)
+		sendCopyError(
+			fmt.Errorf("Invalid destination '%s' for '%s'", destination, source),
+			"The entry can not be copied to the given destination", store, source,
+		)
+	}
+
+	fi, err := os.Stat(filepath.Join(store.Path, source))
+	if err != nil {
+		log.Errorf("Unable to find '%v' in the password store '%+v': %+v", source, store, err)
+		sendCopyError(err, "The entry to copy does not exist", store, source)
+	}
+
+	// Copied password files get the extension of the destination store, e.g. when promoting from pass to passage
+	var files, targets []string
+	if fi.IsDir() {
+		files = listPasswordFiles(store, source, "copy")
+		for _, file := range files {
+			target := strings.TrimSuffix(strings.TrimPrefix(file, source), passwordFileExtension(store))
+			targets = append(targets, destination+target+passwordFileExtension(destinationStore))
+		}
+	} else {
+		requirePasswordFileExtension(store, source, "copy")
+		requirePasswordFileExtension(destinationStore, destination, "copy")
+		files = []string{source}
+		targets = []string{destination}
+	}
+
+	// Check all destinations first, so that a conflict does not leave a partial copy behind
+	for _, target := range targets {
+		requireFileVersion(destinationStore, target, "", !request.Force, "copy")
+	}
+
+	// Like `pass cp`, a directory keeps its own recipients within the store, which the copies are then
+	// encrypted for. Another store has its own recipients, which the copies are always encrypted for instead.
+	if fi.IsDir() && destinationStore.ID == store.ID {
+		if err := copyRecipientsFiles(store, source, destinationStore, destination); err != nil {
+			log.Errorf(
+				"Unable to copy the recipients files of the directory '%v' to '%v' in the password store '%+v': %+v",
+				source, destination, destinationStore, err,
+			)
+			sendCopyError(err, "Unable to copy the recipients files of the directory", store, source)
+		}
+	}
+
+	for i, file := range files {
+		responseData.Files = append(
+			responseData.Files,
+			copyPasswordFile(store, file, crypto, destinationStore, targets[i], destinationCrypto),
+		)
+	}
+
+	responseData.Commit = commitPasswordFiles(
+		destinationStore, []string{destination},
+		fmt.Sprintf("Copy %s to %s.", entryName(store, source), entryName(destinationStore, destination)), "copy",
+	)
+
+	response.SendOk(responseData)
+}
+
+// copyPasswordFile copies a single password file, it is re-encrypted for the recipients at the destination,
+// unless it is copied within the same store and these are the recipients it is already encrypted for
+func copyPasswordFile(
+	store store, from string, crypto backend.Backend,
+	destinationStore store, to string, destinationCrypto backend.Backend,
+) response.MovedFile {
+	copied := response.MovedFile{From: from, To: to, Reencrypted: true}
+	if destinationStore.ID == store.ID {
+		copied.Reencrypted = !sameRecipients(
+			listRecipients(store, from, crypto, "copy"),
+			listRecipients(store, to, crypto, "copy"),
+		)
+	}
+
+	if copied.Reencrypted {
+		contents, _ := decryptPasswordFile(store, from, crypto, "copy")
+		encryptPasswordFile(destinationStore, to, contents, destinationCrypto, "copy")
+		helpers.WipeBytes(contents)
+		return copied
+	}
+
+	toPath := filepath.Join(destinationStore.Path, to)
+	ciphertext, err := os.ReadFile(filepath.Join(store.Path, from))
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(toPath), 0755)
+	}
+	if err == nil {
+		err = helpers.WriteFileAtomically(toPath, ciphertext)
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to copy the password file '%v' to '%v' in the password store '%+v': %+v",
+			from, to, store, err,
+		)
+		sendCopyError(err, "Unable to copy the password file", store, from)
+	}
+
+	return copied
+}
+
+func sendCopyError(err error, message string, store store, file string) {
+	response.SendErrorAndExit(
+		errors.CodeUnableToCopyPasswordFile,
+		&map[errors.Field]string{
+			errors.FieldMessage:   message,
+			errors.FieldAction:    "copy",
+			errors.FieldError:     err.Error(),
+			errors.FieldFile:      file,
+			errors.FieldStoreID:   store.ID,
+			errors.FieldStoreName: store.Name,
+			errors.FieldStorePath: store.Path,
+		},
+	)
+}
@@ -0,0 +1,115 @@
+package request
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/browserpass/browserpass-native/v3/backend"
+	"github.com/browserpass/browserpass-native/v3/errors"
+)
+
+func Test_CopyPasswordFile_SameRecipientsCopiesCiphertext(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, ".gpg-id", "personal/site.gpg")
+	writeTestFile(t, storePath, ".gpg-id", "personal@example.com\n")
+	store := store{ID: "personal", Path: storePath}
+	crypto := backend.NewGpg("gpg")
+
+	// Act
+	copied := copyPasswordFile(store, "personal/site.gpg", crypto, store, "shared/site.gpg", crypto)
+
+	// Assert
+	if copied.Reencrypted {
+		t.Fatalf("The password file must not be re-encrypted for the same recipients")
+	}
+
+	actual, err := os.ReadFile(filepath.Join(storePath, "shared", "site.gpg"))
+	if err != nil {
+		t.Fatalf("Unable to read the copied password file: %v", err)
+	}
+
+	if string(actual) != "personal/site.gpg" {
+		t.Fatalf("The copied password file has unexpected contents '%s'", actual)
+	}
+
+	if _, err := os.Stat(filepath.Join(storePath, "personal", "site.gpg")); err != nil {
+		t.Fatalf("The source password file must be kept: %v", err)
+	}
+}
+
+func Test_CopyPasswordFiles_KeepsNestedRecipients(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"work/site.age": "hunter2\n"})
+	addTestAgeRecipient(t, store, "work")
+	request := makeTestRequest("copy", store, "work")
+	request.Destination = "shared/work"
+
+	// Act
+	actual := handleTestRequest(t, request)
+
+	// Assert
+	if actual["status"] != "ok" {
+		t.Fatalf("Expected the copy to succeed, but got %+v", actual)
+	}
+
+	files := actual["data"].(map[string]interface{})["files"].([]interface{})
+	if len(files) != 1 || files[0].(map[string]interface{})["reencrypted"] != false {
+		t.Fatalf("The copy must keep the recipients of the nested `.age-recipients`, but got %+v", files)
+	}
+
+	expected, _ := os.ReadFile(filepath.Join(store.Path, "work", ".age-recipients"))
+	copied, err := os.ReadFile(filepath.Join(store.Path, "shared", "work", ".age-recipients"))
+	if err != nil || string(copied) != string(expected) {
+		t.Fatalf("The nested `.age-recipients` must be copied along, but got '%s': %v", copied, err)
+	}
+}
+
+func Test_CopyPasswordFiles_RefusesToReplaceRecipients(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"work/site.age": "hunter2\n"})
+	addTestAgeRecipient(t, store, "work")
+	addTestAgeRecipient(t, store, "shared/work")
+	request := makeTestRequest("copy", store, "work")
+	request.Destination = "shared/work"
+
+	// Act
+	actual := handleTestRequest(t, request)
+
+	// Assert
+	if actual["status"] != "error" || actual["code"] != float64(errors.CodeUnableToCopyPasswordFile) {
+		t.Fatalf("Expected the copy to fail, but got %+v", actual)
+	}
+
+	if _, err := os.Stat(filepath.Join(store.Path, "shared", "work", "site.age")); !os.IsNotExist(err) {
+		t.Fatalf("Nothing must be copied, but got: %v", err)
+	}
+}
+
+func Test_CopyPasswordFiles_AnotherStoreUsesItsOwnRecipients(t *testing.T) {
+	// Arrange
+	personal := makeTestAgeStore(t, map[string]string{"work/site.age": "hunter2\n"})
+	addTestAgeRecipient(t, personal, "work")
+	team := makeTestAgeStore(t, map[string]string{})
+	team.ID = "team"
+	request := makeTestRequest("copy", personal, "work")
+	request.Settings.Stores[team.ID] = team
+	request.DestinationStoreID = team.ID
+	request.Destination = "imported/work"
+
+	// Act
+	actual := handleTestRequest(t, request)
+
+	// Assert
+	if actual["status"] != "ok" {
+		t.Fatalf("Expected the copy to succeed, but got %+v", actual)
+	}
+
+	if _, err := os.Stat(filepath.Join(team.Path, "imported", "work", ".age-recipients")); !os.IsNotExist(err) {
+		t.Fatalf("The recipients file of the source store must not be copied into another store, but got: %v", err)
+	}
+
+	if contents := readTestAgeFile(t, team, "imported/work/site.age"); contents != "hunter2\n" {
+		t.Fatalf("The copy has unexpected contents '%s'", contents)
+	}
+}
@@ -1,12 +1,11 @@
 package request
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/browserpass/browserpass-native/v3/errors"
-	"github.com/browserpass/browserpass-native/v3/helpers"
 	"github.com/browserpass/browserpass-native/v3/response"
 	log "github.com/sirupsen/logrus"
 )
@@ -14,57 +13,14 @@ import (
 func deleteFile(request *request) {
 	responseData := response.MakeDeleteResponse()
 
-	if !strings.HasSuffix(request.File, ".gpg") {
-		log.Errorf("The requested password file '%v' does not have the expected '.gpg' extension", request.File)
-		response.SendErrorAndExit(
-			errors.CodeInvalidPasswordFileExtension,
-			&map[errors.Field]string{
-				errors.FieldMessage: "The requested password file does not have the expected '.gpg' extension",
-				errors.FieldAction:  "delete",
-				errors.FieldFile:    request.File,
-			},
-		)
-	}
-
-	store, ok := request.Settings.Stores[request.StoreID]
-	if !ok {
-		log.Errorf(
-			"The password store with ID '%v' is not present in the list of stores '%+v'",
-			request.StoreID, request.Settings.Stores,
-		)
-		response.SendErrorAndExit(
-			errors.CodeInvalidPasswordStore,
-			&map[errors.Field]string{
-				errors.FieldMessage: "The password store is not present in the list of stores",
-				errors.FieldAction:  "delete",
-				errors.FieldStoreID: request.StoreID,
-			},
-		)
-	}
-
-	normalizedStorePath, err := normalizePasswordStorePath(store.Path)
-	if err != nil {
-		log.Errorf(
-			"The password store '%+v' is not accessible at its location: %+v",
-			store, err,
-		)
-		response.SendErrorAndExit(
-			errors.CodeInaccessiblePasswordStore,
-			&map[errors.Field]string{
-				errors.FieldMessage:   "The password store is not accessible",
-				errors.FieldAction:    "delete",
-				errors.FieldError:     err.Error(),
-				errors.FieldStoreID:   store.ID,
-				errors.FieldStoreName: store.Name,
-				errors.FieldStorePath: store.Path,
-			},
-		)
-	}
-	store.Path = normalizedStorePath
+	store := getRequestedStore(request, request.StoreID, "delete")
+	requirePasswordFileExtension(store, request.File, "delete")
+	requireFileVersion(store, request.File, request.IfMatch, false, "delete")
 
 	filePath := filepath.Join(store.Path, request.File)
 
-	err = os.Remove(filePath)
+	archivePasswordFile(store, request.File, "delete")
+	err := os.Remove(filePath)
 	if err != nil {
 		log.Error("Unable to delete the password file: ", err)
 		response.SendErrorAndExit(
@@ -81,52 +37,11 @@ func deleteFile(request *request) {
 		)
 	}
 
-	parentDir := filepath.Dir(filePath)
-	for {
-		if parentDir == store.Path {
-			break
-		}
-
-		isEmpty, err := helpers.IsDirectoryEmpty(parentDir)
-		if err != nil {
-			log.Error("Unable to determine if directory is empty and can be deleted: ", err)
-			response.SendErrorAndExit(
-				errors.CodeUnableToDetermineIsDirectoryEmpty,
-				&map[errors.Field]string{
-					errors.FieldMessage:   "Unable to determine if directory is empty and can be deleted",
-					errors.FieldAction:    "delete",
-					errors.FieldError:     err.Error(),
-					errors.FieldDirectory: parentDir,
-					errors.FieldStoreID:   store.ID,
-					errors.FieldStoreName: store.Name,
-					errors.FieldStorePath: store.Path,
-				},
-			)
-		}
+	removeEmptyParentDirectories(store, filePath, "delete")
 
-		if !isEmpty {
-			break
-		}
-
-		err = os.Remove(parentDir)
-		if err != nil {
-			log.Error("Unable to delete the empty directory: ", err)
-			response.SendErrorAndExit(
-				errors.CodeUnableToDeleteEmptyDirectory,
-				&map[errors.Field]string{
-					errors.FieldMessage:   "Unable to delete the empty directory",
-					errors.FieldAction:    "delete",
-					errors.FieldError:     err.Error(),
-					errors.FieldDirectory: parentDir,
-					errors.FieldStoreID:   store.ID,
-					errors.FieldStoreName: store.Name,
-					errors.FieldStorePath: store.Path,
-				},
-			)
-		}
-
-		parentDir = filepath.Dir(parentDir)
-	}
+	responseData.Commit = commitPasswordFiles(
+		store, []string{request.File}, fmt.Sprintf("Remove %s from store.", entryName(store, request.File)), "delete",
+	)
 
 	response.SendOk(responseData)
 }
@@ -0,0 +1,66 @@
+package request
+
+import (
+	"encoding/base64"
+	"unicode/utf8"
+
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+// Possible encodings of the contents of a password file in requests and responses
+const (
+	encodingUTF8   = "utf8"
+	encodingBase64 = "base64"
+)
+
+// requireValidEncoding sends an error response if the requested encoding is unknown
+func requireValidEncoding(encoding string, action string) {
+	switch encoding {
+	case "", encodingUTF8, encodingBase64:
+		return
+	}
+
+	log.Errorf("The requested contents encoding '%v' is invalid", encoding)
+	response.SendErrorAndExit(
+		errors.CodeInvalidContentsEncoding,
+		&map[errors.Field]string{
+			errors.FieldMessage: "The contents encoding must be either utf8 or base64",
+			errors.FieldAction:  action,
+			errors.FieldError:   "Unknown encoding: " + encoding,
+		},
+	)
+}
+
+// decodeContents returns the raw contents of a password file sent by the browser extension,
+// sending an error response if the contents are not valid base64 when they are expected to be
+func decodeContents(contents string, encoding string, action string) []byte {
+	requireValidEncoding(encoding, action)
+	if encoding != encodingBase64 {
+		return []byte(contents)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(contents)
+	if err != nil {
+		log.Error("Unable to decode the base64 encoded contents: ", err)
+		response.SendErrorAndExit(
+			errors.CodeInvalidContentsEncoding,
+			&map[errors.Field]string{
+				errors.FieldMessage: "Unable to decode the base64 encoded contents",
+				errors.FieldAction:  action,
+				errors.FieldError:   err.Error(),
+			},
+		)
+	}
+	return decoded
+}
+
+// encodeContents converts the decrypted contents of a password file to a string that survives JSON serialization,
+// contents that are not valid UTF-8 are always encoded with base64, whatever the requested encoding is
+func encodeContents(contents []byte, encoding string) (string, string) {
+	if encoding == encodingBase64 || !utf8.Valid(contents) {
+		return base64.StdEncoding.EncodeToString(contents), encodingBase64
+	}
+	return string(contents), encodingUTF8
+}
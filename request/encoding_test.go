@@ -0,0 +1,52 @@
+package request
+
+import (
+	"testing"
+)
+
+func Test_EncodeContents_Text(t *testing.T) {
+	// Arrange
+	contents := []byte("hunter2\nlogin: alice")
+
+	// Act
+	encoded, encoding := encodeContents(contents, "")
+
+	// Assert
+	if encoding != encodingUTF8 {
+		t.Fatalf("Expected the encoding '%v', but got '%v'", encodingUTF8, encoding)
+	}
+
+	if encoded != string(contents) {
+		t.Fatalf("Expected the contents to be returned as is, but got '%v'", encoded)
+	}
+}
+
+func Test_EncodeContents_BinarySwitchesToBase64(t *testing.T) {
+	// Arrange
+	contents := []byte{0x30, 0x82, 0xff, 0x00, 0x0a}
+
+	// Act
+	encoded, encoding := encodeContents(contents, encodingUTF8)
+
+	// Assert
+	if encoding != encodingBase64 {
+		t.Fatalf("Expected the encoding '%v', but got '%v'", encodingBase64, encoding)
+	}
+
+	if encoded != "MIL/AAo=" {
+		t.Fatalf("Expected the base64 encoded contents, but got '%v'", encoded)
+	}
+}
+
+func Test_EncodeContents_RequestedBase64(t *testing.T) {
+	// Arrange
+	contents := []byte("hunter2")
+
+	// Act
+	encoded, encoding := encodeContents(contents, encodingBase64)
+
+	// Assert
+	if encoding != encodingBase64 || encoded != "aHVudGVyMg==" {
+		t.Fatalf("Expected the base64 encoded contents, but got '%v' in '%v'", encoded, encoding)
+	}
+}
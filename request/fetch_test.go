@@ -0,0 +1,53 @@
+package request
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func Test_ExtractFields_OnlyRequestedValuesAndWipesBuffer(t *testing.T) {
+	// Arrange
+	contents := []byte("hunter2\r\nlogin: alice\r\nrecovery: 1111 2222\r\nsecret notes\r\nmore notes\r\n")
+	expected := map[string]string{
+		"password": "hunter2",
+		"user":     "alice",
+	}
+
+	// Act
+	actual := extractFields(contents, "alice.gpg", []string{"password", "user", "missing"}, nil)
+
+	// Assert
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("The fields were extracted incorrectly.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+
+	if !bytes.Equal(contents, make([]byte, len(contents))) {
+		t.Fatalf("The buffer with the decrypted contents was not wiped: %q", contents)
+	}
+}
+
+func Test_FetchDecryptedContents_FieldsOnly(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"site.age": "hunter2\nlogin: alice\nsecret notes\n"})
+	request := makeTestRequest("fetch", store, "site.age")
+	request.Fields = []string{"password"}
+
+	// Act
+	actual := handleTestRequest(t, request)
+
+	// Assert
+	data, ok := actual["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected the requested fields, but got %+v", actual)
+	}
+
+	if _, ok := data["contents"]; ok {
+		t.Fatalf("The decrypted contents must not be part of the response, but got %+v", data)
+	}
+
+	expected := map[string]interface{}{"password": "hunter2"}
+	if !reflect.DeepEqual(data["fields"], expected) {
+		t.Fatalf("Expected only the requested fields %+v, but got %+v", expected, data["fields"])
+	}
+}
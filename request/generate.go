@@ -0,0 +1,143 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/generate"
+	"github.com/browserpass/browserpass-native/v3/helpers"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+func generatePassword(request *request) {
+	responseData := response.MakeGenerateResponse()
+
+	policy := generate.DefaultPolicy()
+
+	var err error
+	if len(request.Policy) > 0 {
+		err = json.Unmarshal(request.Policy, policy)
+	}
+
+	// The folder policies are applied last, so that the browser can not weaken the rules of the store
+	var store store
+	if err == nil && (request.StoreID != "" || request.Save) {
+		store = getRequestedStore(request, request.StoreID, "generate")
+		applyFolderPolicies(store, request.File, policy)
+	}
+
+	var password string
+	if err == nil {
+		password, err = generate.Generate(policy)
+	}
+	if err != nil {
+		log.Errorf("Unable to generate a password with the policy '%+v': %+v", policy, err)
+		response.SendErrorAndExit(
+			errors.CodeInvalidPasswordPolicy,
+			&map[errors.Field]string{
+				errors.FieldMessage: "Unable to generate a password with the given policy",
+				errors.FieldAction:  "generate",
+				errors.FieldError:   err.Error(),
+			},
+		)
+	}
+	responseData.Policy = policy
+
+	if !request.Save {
+		responseData.Password = password
+		response.SendOk(responseData)
+		return
+	}
+
+	// The generated password is stored right away and never sent to the browser
+	requirePasswordFileExtension(store, request.File, "generate")
+	crypto := getBackend(request, store, "generate")
+	requireFileVersion(store, request.File, request.IfMatch, request.IfNoneMatch, "generate")
+
+	// Same as `pass generate --in-place`, only the first line of an existing entry is replaced
+	contents := []byte(password + "\n")
+	message := fmt.Sprintf("Add generated password for %s.", entryName(store, request.File))
+	if _, err := os.Stat(filepath.Join(store.Path, request.File)); err == nil {
+		decrypted, _ := decryptPasswordFile(store, request.File, crypto, "generate")
+		contents = replaceFirstLine(decrypted, password)
+		helpers.WipeBytes(decrypted)
+		message = fmt.Sprintf("Replace generated password for %s.", entryName(store, request.File))
+	}
+
+	archivePasswordFile(store, request.File, "generate")
+	encryptPasswordFile(store, request.File, contents, crypto, "generate")
+	helpers.WipeBytes(contents)
+
+	responseData.Version = getFileVersion(store, request.File, "generate")
+	responseData.Commit = commitPasswordFiles(store, []string{request.File}, message, "generate")
+
+	response.SendOk(responseData)
+}
+
+// applyFolderPolicies applies the `generate` policies from the `.browserpass.json` files in the store root
+// and in every directory down to the directory of the password file, so the nearest policy wins
+// and only needs to list the rules it changes
+func applyFolderPolicies(store store, file string, policy *generate.Policy) {
+	dirs := []string{""}
+	if dir := path.Dir(filepath.ToSlash(file)); file != "" && dir != "." {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+		}
+	}
+
+	for _, dir := range dirs {
+		settingsPath := filepath.Join(store.Path, filepath.FromSlash(dir), ".browserpass.json")
+		content, err := os.ReadFile(settingsPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		var settings struct {
+			Generate json.RawMessage `json:"generate"`
+		}
+		if err == nil {
+			err = json.Unmarshal(content, &settings)
+		}
+		if err == nil && len(settings.Generate) > 0 {
+			err = json.Unmarshal(settings.Generate, policy)
+		}
+		if err != nil {
+			log.Errorf("Unable to read the password policy from '%v' in the password store '%+v': %+v", settingsPath, store, err)
+			response.SendErrorAndExit(
+				errors.CodeUnreadablePasswordStoreDefaultSettings,
+				&map[errors.Field]string{
+					errors.FieldMessage:   "Unable to read .browserpass.json of the password store",
+					errors.FieldAction:    "generate",
+					errors.FieldError:     err.Error(),
+					errors.FieldDirectory: dir,
+					errors.FieldStoreID:   store.ID,
+					errors.FieldStoreName: store.Name,
+					errors.FieldStorePath: store.Path,
+				},
+			)
+		}
+	}
+}
+
+// replaceFirstLine replaces the password on the first line of an entry, keeping its line ending and all other lines
+func replaceFirstLine(contents []byte, password string) []byte {
+	end := bytes.IndexByte(contents, '\n')
+	if end < 0 {
+		return []byte(password)
+	}
+	if end > 0 && contents[end-1] == '\r' {
+		end--
+	}
+
+	replaced := make([]byte, 0, len(password)+len(contents)-end)
+	replaced = append(replaced, password...)
+	return append(replaced, contents[end:]...)
+}
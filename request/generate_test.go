@@ -0,0 +1,67 @@
+package request
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/browserpass/browserpass-native/v3/generate"
+)
+
+func Test_ReplaceFirstLine_KeepsLineEndings(t *testing.T) {
+	// Arrange
+	contents := []byte("old\r\nlogin: alice\r\n\r\nnotes\r\n")
+
+	// Act
+	actual := replaceFirstLine(contents, "new")
+
+	// Assert
+	expected := "new\r\nlogin: alice\r\n\r\nnotes\r\n"
+	if string(actual) != expected {
+		t.Fatalf("Expected '%q', but got '%q'", expected, actual)
+	}
+}
+
+func Test_ApplyFolderPolicies_NearestPolicyWins(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, ".browserpass.json", "bank/.browserpass.json", "bank/online/site.gpg")
+	writeTestFile(t, storePath, ".browserpass.json", `{"gpgPath": "gpg", "generate": {"length": 30, "excludeLookalikes": true}}`)
+	writeTestFile(t, storePath, "bank/.browserpass.json", `{"generate": {"length": 16, "classes": ["lower", "upper", "digits"]}}`)
+	policy := generate.DefaultPolicy()
+
+	// Act
+	applyFolderPolicies(store{Path: storePath}, "bank/online/site.gpg", policy)
+
+	// Assert
+	if policy.Length != 16 || len(policy.Classes) != 3 {
+		t.Fatalf("Expected the policy of the bank directory, but got %+v", policy)
+	}
+
+	if !policy.ExcludeLookalikes {
+		t.Fatalf("Expected the rules not changed by the bank directory to be inherited from the store root, but got %+v", policy)
+	}
+}
+
+func Test_GeneratePassword_FolderPolicyWinsOverRequest(t *testing.T) {
+	// Arrange
+	store := store{ID: "test", Path: makeTestStore(t, "bank/.browserpass.json")}
+	writeTestFile(t, store.Path, "bank/.browserpass.json", `{"generate": {"length": 16}}`)
+	request := makeTestRequest("generate", store, "bank/site.gpg")
+	request.Policy = json.RawMessage(`{"length": 8, "excludeLookalikes": true}`)
+
+	// Act
+	actual := handleTestRequest(t, request)
+
+	// Assert
+	data, ok := actual["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected a generated password, but got %+v", actual)
+	}
+
+	if password := data["password"].(string); len(password) != 16 {
+		t.Fatalf("Expected the length of the folder policy, but got the password '%v'", password)
+	}
+
+	if policy := data["policy"].(map[string]interface{}); policy["excludeLookalikes"] != true {
+		t.Fatalf("Expected the rules not set by the folder policy to come from the request, but got %+v", policy)
+	}
+}
@@ -0,0 +1,141 @@
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+// isGitStore checks whether the password store is a git repository, same as pass does
+func isGitStore(store store) bool {
+	_, err := os.Stat(filepath.Join(store.Path, ".git"))
+	return err == nil
+}
+
+// runGit runs the system git binary in the password store directory and returns its trimmed output.
+// git must never wait for user input, as there is no terminal to answer it.
+func runGit(store store, args ...string) (string, error) {
+	return runGitWithEnv(store, []string{"GIT_TERMINAL_PROMPT=0"}, args...)
+}
+
+// runGitRemote runs a git command that connects to a remote repository. Unless the user has configured
+// their own SSH command, ssh runs in batch mode, so that it fails instead of asking about an unknown host key
+// or for the passphrase of a key.
+func runGitRemote(store store, args ...string) (string, error) {
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+	if os.Getenv("GIT_SSH_COMMAND") == "" && os.Getenv("GIT_SSH") == "" {
+		if sshCommand, _ := runGit(store, "config", "--get", "core.sshCommand"); sshCommand == "" {
+			env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+		}
+	}
+	return runGitWithEnv(store, env, args...)
+}
+
+func runGitWithEnv(store store, env []string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", store.Path}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return strings.TrimSpace(stdout.String()), fmt.Errorf("Error: %s, Stderr: %s", err.Error(), stderr.String())
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// commitPasswordFiles commits the changes of password files or directories (including their removal)
+// with a pass-style message, and returns the hash of the new commit. Nothing is committed if the store
+// is not a git repository or if nothing has changed, and other changes in the store are never committed along.
+func commitPasswordFiles(store store, files []string, message string, action string) string {
+	if !isGitStore(store) {
+		return ""
+	}
+
+	// Paths that are gone are only committed if git tracked them, an untracked entry that was deleted
+	// or moved away leaves nothing to commit, and naming it would make git fail on an unmatched pathspec
+	var err error
+	paths := []string{"--"}
+	for _, file := range files {
+		file = filepath.ToSlash(file)
+		if _, statErr := os.Lstat(filepath.Join(store.Path, file)); statErr == nil {
+			if _, err = runGit(store, "add", "--all", "--", file); err != nil {
+				break
+			}
+			paths = append(paths, file)
+			continue
+		}
+
+		var tracked string
+		if tracked, err = runGit(store, "ls-files", "--", file); err != nil {
+			break
+		}
+		if tracked != "" {
+			if _, err = runGit(store, "rm", "--cached", "--ignore-unmatch", "-r", "--quiet", "--", file); err != nil {
+				break
+			}
+			paths = append(paths, file)
+		}
+	}
+	if err == nil && len(paths) == 1 {
+		return ""
+	}
+
+	var status string
+	if err == nil {
+		status, err = runGit(store, append([]string{"status", "--porcelain"}, paths...)...)
+	}
+	if err == nil && status == "" {
+		return ""
+	}
+
+	// Same as pass, commits are signed if the pass.signcommits git option is set
+	var sign string
+	if err == nil {
+		sign, _ = runGit(store, "config", "--bool", "--get", "pass.signcommits")
+	}
+	if err == nil {
+		args := []string{"commit", "--quiet", "--message", message}
+		if sign == "true" {
+			args = append(args, "--gpg-sign")
+		}
+		_, err = runGit(store, append(args, paths...)...)
+	}
+
+	var hash string
+	if err == nil {
+		hash, err = runGit(store, "rev-parse", "HEAD")
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to commit the change of the password files '%v' in the password store '%+v': %+v",
+			files, store, err,
+		)
+		response.SendErrorAndExit(
+			errors.CodeUnableToCommitChange,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "The password file was changed, but the change could not be committed to git",
+				errors.FieldAction:    action,
+				errors.FieldError:     err.Error(),
+				errors.FieldFile:      strings.Join(files, ","),
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+
+	return hash
+}
+
+// entryName returns the name of a password entry as used by pass, which is the path without the extension
+func entryName(store store, file string) string {
+	return strings.TrimSuffix(filepath.ToSlash(file), passwordFileExtension(store))
+}
@@ -0,0 +1,142 @@
+package request
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func makeTestGitStore(t *testing.T, files ...string) store {
+	store := store{ID: "test", Path: makeTestStore(t, files...)}
+	initTestGitStore(t, store)
+	return store
+}
+
+// initTestGitStore turns a test store into a git repository and commits all of its files
+func initTestGitStore(t *testing.T, store store) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("The git binary is not available")
+	}
+
+	t.Setenv("GIT_AUTHOR_NAME", "Test User")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test User")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	for _, args := range [][]string{{"init", "--quiet"}, {"add", "--all"}, {"commit", "--quiet", "--message", "init"}} {
+		if _, err := runGit(store, args...); err != nil {
+			t.Fatalf("Unable to initialize the test git store: %v", err)
+		}
+	}
+}
+
+func Test_CommitPasswordFile_OnlyCommitsTheFile(t *testing.T) {
+	// Arrange
+	store := makeTestGitStore(t, ".gpg-id", "site.gpg")
+	writeTestFile(t, store.Path, "site.gpg", "modified")
+	writeTestFile(t, store.Path, ".gpg-id", "modified")
+
+	// Act
+	hash := commitPasswordFiles(store, []string{"site.gpg"}, "Edit password for site using browserpass.", "save")
+
+	// Assert
+	if hash == "" {
+		t.Fatalf("Expected the hash of the new commit, but got none")
+	}
+
+	message, err := runGit(store, "log", "-1", "--format=%s")
+	if err != nil || message != "Edit password for site using browserpass." {
+		t.Fatalf("Expected the pass-style commit message, but got '%v' (%v)", message, err)
+	}
+
+	status, err := runGit(store, "status", "--porcelain")
+	if err != nil || status != "M .gpg-id" {
+		t.Fatalf("Expected the other change to be left uncommitted, but got '%v' (%v)", status, err)
+	}
+}
+
+func Test_CommitPasswordFile_NothingChanged(t *testing.T) {
+	// Arrange
+	store := makeTestGitStore(t, "site.gpg")
+
+	// Act
+	hash := commitPasswordFiles(store, []string{"site.gpg"}, "Edit password for site using browserpass.", "save")
+
+	// Assert
+	if hash != "" {
+		t.Fatalf("Expected no commit for an unchanged file, but got '%v'", hash)
+	}
+}
+
+func Test_CommitPasswordFile_DeletedUntrackedFile(t *testing.T) {
+	// Arrange
+	store := makeTestGitStore(t, "site.gpg")
+	writeTestFile(t, store.Path, "untracked.gpg", "untracked")
+	if err := os.Remove(filepath.Join(store.Path, "untracked.gpg")); err != nil {
+		t.Fatalf("Unable to delete the untracked file: %v", err)
+	}
+
+	// Act
+	var hash string
+	code := recoverErrorCode(t, func() {
+		hash = commitPasswordFiles(store, []string{"untracked.gpg"}, "Remove untracked from store.", "delete")
+	})
+
+	// Assert
+	if code != 0 || hash != "" {
+		t.Fatalf("Expected nothing to commit without an error, but got the code %v and the commit '%v'", code, hash)
+	}
+}
+
+func Test_CommitPasswordFile_MovedUntrackedFile(t *testing.T) {
+	// Arrange
+	store := makeTestGitStore(t, "site.gpg")
+	writeTestFile(t, store.Path, "moved.gpg", "untracked")
+
+	// Act
+	var hash string
+	code := recoverErrorCode(t, func() {
+		hash = commitPasswordFiles(store, []string{"untracked.gpg", "moved.gpg"}, "Rename untracked to moved.", "move")
+	})
+
+	// Assert
+	if code != 0 || hash == "" {
+		t.Fatalf("Expected the destination to be committed, but got the code %v and the commit '%v'", code, hash)
+	}
+
+	files, err := runGit(store, "show", "--name-only", "--format=", "HEAD")
+	if err != nil || files != "moved.gpg" {
+		t.Fatalf("Expected only the destination in the commit, but got '%v' (%v)", files, err)
+	}
+}
+
+func Test_SyncStore_ReportsConflicts(t *testing.T) {
+	// Arrange
+	remote := makeTestGitStore(t, "site.gpg")
+	local := store{ID: "local", Path: t.TempDir()}
+	if _, err := runGit(local, "clone", "--quiet", remote.Path, "."); err != nil {
+		t.Fatalf("Unable to clone the test git store: %v", err)
+	}
+
+	writeTestFile(t, remote.Path, "site.gpg", "remote")
+	commitPasswordFiles(remote, []string{"site.gpg"}, "Edit password for site using browserpass.", "save")
+	writeTestFile(t, local.Path, "site.gpg", "local")
+	commitPasswordFiles(local, []string{"site.gpg"}, "Edit password for site using browserpass.", "save")
+
+	// Act
+	result := syncStore(local, true, true)
+
+	// Assert
+	if len(result.Conflicts) != 1 || result.Conflicts[0] != "site.gpg" {
+		t.Fatalf("Expected a conflict in 'site.gpg', but got %v", result.Conflicts)
+	}
+
+	if result.Ahead != 1 || result.Behind != 1 || result.Pulled || result.Pushed {
+		t.Fatalf("Expected the diverged branches to be left alone, but got %+v", result)
+	}
+
+	if isRebaseInProgress(local) {
+		t.Fatalf("Expected the conflicting rebase to be aborted")
+	}
+}
@@ -0,0 +1,229 @@
+package request
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/helpers"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+// historyDirectory the hidden directory in the store root that keeps the previous versions of password files,
+// it is skipped by the store walker
+const historyDirectory = ".history"
+
+// historyTimestampFormat names the archived versions, so that sorting the names sorts them chronologically
+const historyTimestampFormat = "20060102T150405.000000000Z"
+
+// defaultHistorySize the number of previous versions kept per password file in stores that are not git repositories
+const defaultHistorySize = 10
+
+func listPasswordFileHistory(request *request) {
+	responseData := response.MakeHistoryResponse()
+
+	store := getRequestedStore(request, request.StoreID, "history")
+	requirePasswordFileExtension(store, request.File, "history")
+
+	timestamps, err := listHistory(store, request.File)
+	if err != nil {
+		log.Errorf(
+			"Unable to list the history of the password file '%v' in the password store '%+v': %+v",
+			request.File, store, err,
+		)
+		sendHistoryError(err, "Unable to list the history of the password file", store, request.File, "history")
+	}
+
+	for _, timestamp := range timestamps {
+		date, _ := time.Parse(historyTimestampFormat, timestamp)
+		responseData.Versions = append(responseData.Versions, response.HistoryVersion{
+			Timestamp: timestamp,
+			Date:      date.Format(time.RFC3339),
+		})
+	}
+
+	response.SendOk(responseData)
+}
+
+func restorePasswordFile(request *request) {
+	responseData := response.MakeRestoreResponse()
+
+	store := getRequestedStore(request, request.StoreID, "restore")
+	requirePasswordFileExtension(store, request.File, "restore")
+	crypto := getBackend(request, store, "restore")
+	requireFileVersion(store, request.File, request.IfMatch, false, "restore")
+
+	// The timestamp is validated, so that it can never point outside of the history directory
+	archivedFile := path.Join(historyDirectory, entryName(store, request.File), request.Timestamp+passwordFileExtension(store))
+	_, err := time.Parse(historyTimestampFormat, request.Timestamp)
+	if err == nil {
+		_, err = os.Stat(filepath.Join(store.Path, archivedFile))
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to find the version '%v' of the password file '%v' in the password store '%+v': %+v",
+			request.Timestamp, request.File, store, err,
+		)
+		sendHistoryError(err, "The requested version of the password file is not in its history", store, request.File, "restore")
+	}
+
+	// The recipients may have changed since the version was archived, so it is re-encrypted like a saved password,
+	// and the current version is archived in turn
+	contents, _ := decryptPasswordFile(store, archivedFile, crypto, "restore")
+	archivePasswordFile(store, request.File, "restore")
+	encryptPasswordFile(store, request.File, contents, crypto, "restore")
+	helpers.WipeBytes(contents)
+
+	responseData.Version = getFileVersion(store, request.File, "restore")
+	responseData.Commit = commitPasswordFiles(
+		store, []string{request.File},
+		fmt.Sprintf("Restore %s from history using browserpass.", entryName(store, request.File)), "restore",
+	)
+
+	response.SendOk(responseData)
+}
+
+// historySize returns how many previous versions of each password file are kept, by default none
+// in git repositories, which keep the history of the store anyway
+func historySize(store store) int {
+	if store.Settings.HistorySize != nil {
+		return *store.Settings.HistorySize
+	}
+	if isGitStore(store) {
+		return 0
+	}
+	return defaultHistorySize
+}
+
+// historyPath returns the directory with the previous versions of a password file
+func historyPath(store store, file string) string {
+	return filepath.Join(store.Path, historyDirectory, filepath.FromSlash(entryName(store, file)))
+}
+
+// archivePasswordFile keeps a copy of the current ciphertext of a password file before it is overwritten
+// or deleted, and removes the oldest versions beyond the history size of the store
+func archivePasswordFile(store store, file string, action string) {
+	size := historySize(store)
+	if size <= 0 {
+		return
+	}
+
+	ciphertext, err := os.ReadFile(filepath.Join(store.Path, file))
+	if os.IsNotExist(err) {
+		return
+	}
+
+	dir := historyPath(store, file)
+	if err == nil {
+		err = os.MkdirAll(dir, 0700)
+	}
+	if err == nil {
+		name := time.Now().UTC().Format(historyTimestampFormat) + passwordFileExtension(store)
+		err = helpers.WriteFileAtomically(filepath.Join(dir, name), ciphertext)
+	}
+
+	if err == nil {
+		err = pruneHistory(store, file, size)
+	}
+
+	if err != nil {
+		log.Errorf(
+			"Unable to archive the previous version of the password file '%v' in the password store '%+v': %+v",
+			file, store, err,
+		)
+		sendHistoryError(err, "Unable to archive the previous version of the password file", store, file, action)
+	}
+}
+
+// pruneHistory removes the oldest versions of a password file beyond the given history size
+func pruneHistory(store store, file string, size int) error {
+	timestamps, err := listHistory(store, file)
+	for err == nil && len(timestamps) > size {
+		oldest := timestamps[len(timestamps)-1]
+		err = os.Remove(filepath.Join(historyPath(store, file), oldest+passwordFileExtension(store)))
+		timestamps = timestamps[:len(timestamps)-1]
+	}
+	return err
+}
+
+// moveHistory moves the previous versions of a moved password file along with it, so that they are not
+// inherited by a new password file created at the old path later
+func moveHistory(store store, from string, to string, action string) {
+	fromDir := historyPath(store, from)
+	toDir := historyPath(store, to)
+
+	timestamps, err := listHistory(store, from)
+	if err != nil || len(timestamps) == 0 {
+		return
+	}
+
+	err = os.MkdirAll(toDir, 0700)
+	for _, timestamp := range timestamps {
+		if err != nil {
+			break
+		}
+		name := timestamp + passwordFileExtension(store)
+		err = os.Rename(filepath.Join(fromDir, name), filepath.Join(toDir, name))
+	}
+	// The moved versions may join the versions of a password file that was overwritten at the destination
+	if size := historySize(store); err == nil && size > 0 {
+		err = pruneHistory(store, to, size)
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to move the history of the password file '%v' to '%v' in the password store '%+v': %+v",
+			from, to, store, err,
+		)
+		sendHistoryError(err, "Unable to move the history of the password file", store, from, action)
+	}
+
+	removeEmptyParentDirectories(store, filepath.Join(fromDir, timestamps[0]), action)
+}
+
+// listHistory lists the timestamps of the previous versions of a password file, newest first
+func listHistory(store store, file string) ([]string, error) {
+	entries, err := os.ReadDir(historyPath(store, file))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	timestamps := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, passwordFileExtension(store)) {
+			continue
+		}
+
+		timestamp := strings.TrimSuffix(name, passwordFileExtension(store))
+		if _, err := time.Parse(historyTimestampFormat, timestamp); err == nil {
+			timestamps = append(timestamps, timestamp)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(timestamps)))
+	return timestamps, nil
+}
+
+func sendHistoryError(err error, message string, store store, file string, action string) {
+	response.SendErrorAndExit(
+		errors.CodeUnableToAccessPasswordHistory,
+		&map[errors.Field]string{
+			errors.FieldMessage:   message,
+			errors.FieldAction:    action,
+			errors.FieldError:     err.Error(),
+			errors.FieldFile:      file,
+			errors.FieldStoreID:   store.ID,
+			errors.FieldStoreName: store.Name,
+			errors.FieldStorePath: store.Path,
+		},
+	)
+}
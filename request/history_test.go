@@ -0,0 +1,176 @@
+package request
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/browserpass/browserpass-native/v3/backend"
+	"github.com/browserpass/browserpass-native/v3/errors"
+)
+
+func Test_ArchivePasswordFile_KeepsHistorySize(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, "work/site.gpg")
+	size := 2
+	store := store{Path: storePath, Settings: StoreSettings{HistorySize: &size}}
+
+	// Act
+	for _, contents := range []string{"first", "second", "third"} {
+		writeTestFile(t, storePath, "work/site.gpg", contents)
+		archivePasswordFile(store, "work/site.gpg", "save")
+	}
+
+	// Assert
+	timestamps, err := listHistory(store, "work/site.gpg")
+	if err != nil {
+		t.Fatalf("Error listing the history: %v", err)
+	}
+
+	if len(timestamps) != 2 || timestamps[0] <= timestamps[1] {
+		t.Fatalf("Expected the 2 newest versions, newest first, but got %v", timestamps)
+	}
+}
+
+func Test_IndexStore_SkipsHistory(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, "site.gpg", ".history/site/20240101T000000.000000000Z.gpg", "team/.history/kept.gpg")
+
+	// Act
+	index, err := indexStore(store{Path: storePath})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error indexing the store: %v", err)
+	}
+
+	if len(index.Files) != 2 || index.Files[0] != "site.gpg" || index.Files[1] != "team/.history/kept.gpg" {
+		t.Fatalf("Expected only the history directory in the store root to be skipped, but got %v", index.Files)
+	}
+}
+
+func Test_ListPasswordFileHistory_NewestFirst(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"work/site.age": "first\n"})
+	archivePasswordFile(store, "work/site.age", "save")
+	archivePasswordFile(store, "work/site.age", "save")
+	expected, _ := listHistory(store, "work/site.age")
+
+	// Act
+	actual := handleTestRequest(t, makeTestRequest("history", store, "work/site.age"))
+
+	// Assert
+	data, ok := actual["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected the history of the password file, but got %+v", actual)
+	}
+
+	versions := data["versions"].([]interface{})
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 previous versions, but got %+v", versions)
+	}
+
+	for i, version := range versions {
+		version := version.(map[string]interface{})
+		if version["timestamp"] != expected[i] || version["date"] == "" {
+			t.Fatalf("Expected the version '%v' at position %v, but got %+v", expected[i], i, version)
+		}
+	}
+}
+
+func Test_RestorePasswordFile_ReencryptsForCurrentRecipients(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"site.age": "old\n"})
+	archivePasswordFile(store, "site.age", "save")
+	timestamps, _ := listHistory(store, "site.age")
+	identity := addTestAgeRecipient(t, store, "")
+	request := makeTestRequest("restore", store, "site.age")
+	request.Timestamp = timestamps[0]
+
+	// Act
+	actual := handleTestRequest(t, request)
+
+	// Assert
+	if actual["status"] != "ok" {
+		t.Fatalf("Expected the restore to succeed, but got %+v", actual)
+	}
+
+	ciphertext, err := os.ReadFile(filepath.Join(store.Path, "site.age"))
+	var reader io.Reader
+	if err == nil {
+		reader, err = age.Decrypt(bytes.NewReader(ciphertext), identity)
+	}
+	if err != nil {
+		t.Fatalf("The restored password file must be encrypted for the current recipients: %v", err)
+	}
+	if contents, _ := io.ReadAll(reader); string(contents) != "old\n" {
+		t.Fatalf("The restored password file has unexpected contents '%s'", contents)
+	}
+
+	if timestamps, _ := listHistory(store, "site.age"); len(timestamps) != 2 {
+		t.Fatalf("Expected the replaced version to be archived, but got the history %v", timestamps)
+	}
+}
+
+func Test_RestorePasswordFile_RejectsInvalidTimestamp(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"site.age": "current\n"})
+	request := makeTestRequest("restore", store, "site.age")
+	request.Timestamp = "../../site"
+
+	// Act
+	actual := handleTestRequest(t, request)
+
+	// Assert
+	if actual["status"] != "error" || actual["code"] != float64(errors.CodeUnableToAccessPasswordHistory) {
+		t.Fatalf("Expected the restore to fail, but got %+v", actual)
+	}
+
+	if !strings.Contains(readTestAgeFile(t, store, "site.age"), "current") {
+		t.Fatalf("The current password file must be kept")
+	}
+}
+
+func Test_MovePasswordFile_MovesHistory(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"work/site.age": "current\n"})
+	archivePasswordFile(store, "work/site.age", "save")
+	expected, _ := listHistory(store, "work/site.age")
+
+	// Act
+	movePasswordFile(store, "work/site.age", "personal/site.age", backend.NewAge(store.Settings.IdentitiesPath))
+
+	// Assert
+	if actual, _ := listHistory(store, "personal/site.age"); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("Expected the history %v to move along, but got %v", expected, actual)
+	}
+
+	if _, err := os.Stat(filepath.Join(store.Path, historyDirectory, "work")); !os.IsNotExist(err) {
+		t.Fatalf("The history at the old path must be removed, but got: %v", err)
+	}
+}
+
+func Test_ReadSyncStatus_IgnoresHistory(t *testing.T) {
+	// Arrange
+	size := 5
+	store := makeTestGitStore(t, "site.gpg")
+	store.Settings.HistorySize = &size
+	archivePasswordFile(store, "site.gpg", "save")
+
+	// Act
+	result := readSyncStatus(store)
+
+	// Assert
+	if result.Dirty {
+		t.Fatalf("The password history must not make the store dirty")
+	}
+
+	if timestamps, _ := listHistory(store, "site.gpg"); len(timestamps) != 1 {
+		t.Fatalf("Expected the archived version in the history, but got %v", timestamps)
+	}
+}
@@ -0,0 +1,60 @@
+package request
+
+import (
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+func indexStores(request *request) {
+	responseData := response.MakeIndexResponse()
+
+	for _, store := range request.Settings.Stores {
+		normalizedStorePath, err := normalizePasswordStorePath(store.Path)
+		if err != nil {
+			log.Errorf(
+				"The password store '%+v' is not accessible at its location: %+v",
+				store, err,
+			)
+			response.SendErrorAndExit(
+				errors.CodeInaccessiblePasswordStore,
+				&map[errors.Field]string{
+					errors.FieldMessage:   "The password store is not accessible",
+					errors.FieldAction:    "index",
+					errors.FieldError:     err.Error(),
+					errors.FieldStoreID:   store.ID,
+					errors.FieldStoreName: store.Name,
+					errors.FieldStorePath: store.Path,
+				},
+			)
+		}
+
+		store.Path = normalizedStorePath
+		store.backendName = getStoreBackendName(store)
+
+		index, err := indexStore(store)
+		if err != nil {
+			log.Errorf(
+				"Unable to index the files and directories in the password store '%+v' at its location: %+v",
+				store, err,
+			)
+			response.SendErrorAndExit(
+				errors.CodeUnableToListFilesInPasswordStore,
+				&map[errors.Field]string{
+					errors.FieldMessage:   "Unable to index the files and directories in the password store",
+					errors.FieldAction:    "index",
+					errors.FieldError:     err.Error(),
+					errors.FieldStoreID:   store.ID,
+					errors.FieldStoreName: store.Name,
+					errors.FieldStorePath: store.Path,
+				},
+			)
+		}
+
+		responseData.Files[store.ID] = index.Files
+		responseData.Directories[store.ID] = index.Directories
+		responseData.Warnings[store.ID] = index.Warnings
+	}
+
+	response.SendOk(responseData)
+}
@@ -1,19 +1,40 @@
 package request
 
 import (
-	"path/filepath"
-	"sort"
+	"path"
 	"strings"
 
 	"github.com/browserpass/browserpass-native/v3/errors"
 	"github.com/browserpass/browserpass-native/v3/response"
-	"github.com/mattn/go-zglob"
 	log "github.com/sirupsen/logrus"
 )
 
 func listFiles(request *request) {
 	responseData := response.MakeListResponse()
 
+	if request.Offset < 0 || request.Limit < 0 {
+		log.Errorf("The list offset '%v' or limit '%v' is negative", request.Offset, request.Limit)
+		response.SendErrorAndExit(
+			errors.CodeInvalidListFilter,
+			&map[errors.Field]string{
+				errors.FieldMessage: "The list offset and limit must not be negative",
+				errors.FieldAction:  "list",
+			},
+		)
+	}
+
+	if err := validateGlob(request.Glob); err != nil {
+		log.Errorf("The list glob pattern '%v' is invalid: %+v", request.Glob, err)
+		response.SendErrorAndExit(
+			errors.CodeInvalidListFilter,
+			&map[errors.Field]string{
+				errors.FieldMessage: "The list glob pattern is invalid",
+				errors.FieldAction:  "list",
+				errors.FieldError:   err.Error(),
+			},
+		)
+	}
+
 	for _, store := range request.Settings.Stores {
 		normalizedStorePath, err := normalizePasswordStorePath(store.Path)
 		if err != nil {
@@ -35,8 +56,9 @@ func listFiles(request *request) {
 		}
 
 		store.Path = normalizedStorePath
+		store.backendName = getStoreBackendName(store)
 
-		files, err := zglob.GlobFollowSymlinks(filepath.Join(store.Path, "/**/*.gpg"))
+		index, err := indexStore(store)
 		if err != nil {
 			log.Errorf(
 				"Unable to list the files in the password store '%+v' at its location: %+v",
@@ -55,32 +77,84 @@ func listFiles(request *request) {
 			)
 		}
 
-		for i, file := range files {
-			relativePath, err := filepath.Rel(store.Path, file)
-			if err != nil {
-				log.Errorf(
-					"Unable to determine the relative path for a file '%v' in the password store '%+v': %+v",
-					file, store, err,
-				)
-				response.SendErrorAndExit(
-					errors.CodeUnableToDetermineRelativeFilePathInPasswordStore,
-					&map[errors.Field]string{
-						errors.FieldMessage:   "Unable to determine the relative path for a file in the password store",
-						errors.FieldAction:    "list",
-						errors.FieldError:     err.Error(),
-						errors.FieldFile:      file,
-						errors.FieldStoreID:   store.ID,
-						errors.FieldStoreName: store.Name,
-						errors.FieldStorePath: store.Path,
-					},
-				)
+		responseData.Files[store.ID], responseData.Total[store.ID] = filterFiles(
+			index.Files, request.Prefix, request.Glob, request.Offset, request.Limit,
+		)
+		responseData.Warnings[store.ID] = index.Warnings
+	}
+
+	response.SendOk(responseData)
+}
+
+// filterFiles returns the requested page of files matching both the prefix and the glob pattern,
+// as well as the total number of matching files
+func filterFiles(files []string, prefix string, glob string, offset int, limit int) ([]string, int) {
+	matching := []string{}
+	for _, file := range files {
+		if !strings.HasPrefix(file, prefix) {
+			continue
+		}
+		if matched, _ := matchGlob(glob, file); !matched {
+			continue
+		}
+		matching = append(matching, file)
+	}
+
+	total := len(matching)
+	if offset > total {
+		offset = total
+	}
+	matching = matching[offset:]
+	if limit > 0 && limit < len(matching) {
+		matching = matching[:limit]
+	}
+
+	return matching, total
+}
+
+// validateGlob checks the syntax of every segment of a glob pattern
+func validateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchGlob matches a relative path against a glob pattern, in addition to the syntax
+// supported by path.Match, a `**` path segment matches any number of directories.
+// An empty pattern matches everything.
+func matchGlob(pattern string, name string) (bool, error) {
+	if pattern == "" {
+		return true, nil
+	}
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(patterns []string, names []string) (bool, error) {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if matched, err := matchGlobSegments(patterns[1:], names[i:]); matched || err != nil {
+					return matched, err
+				}
 			}
-			files[i] = strings.Replace(relativePath, "\\", "/", -1) // normalize Windows paths
+			return false, nil
+		}
+
+		if len(names) == 0 {
+			return false, nil
 		}
 
-		sort.Strings(files)
-		responseData.Files[store.ID] = files
+		matched, err := path.Match(patterns[0], names[0])
+		if !matched || err != nil {
+			return false, err
+		}
+
+		patterns = patterns[1:]
+		names = names[1:]
 	}
 
-	response.SendOk(responseData)
+	return len(names) == 0, nil
 }
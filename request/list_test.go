@@ -0,0 +1,54 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_FilterFiles_PrefixGlobAndPage(t *testing.T) {
+	// Arrange
+	files := []string{
+		"bank.gpg",
+		"work/bank/main.gpg",
+		"work/bank/savings.gpg",
+		"work/email.gpg",
+		"work/servers/bank-db.gpg",
+	}
+	expected := []string{"work/bank/savings.gpg", "work/servers/bank-db.gpg"}
+	expectedTotal := 3
+
+	// Act
+	actual, actualTotal := filterFiles(files, "work/", "**/*bank*/**", 1, 2)
+
+	// Assert
+	if expectedTotal != actualTotal {
+		t.Fatalf("The actual total '%v' does not match the expected value of '%v'", actualTotal, expectedTotal)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("The files were filtered incorrectly.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
+
+func Test_FilterFiles_OffsetPastTheEnd(t *testing.T) {
+	// Arrange
+	files := []string{"a.gpg", "b.gpg"}
+
+	// Act
+	actual, actualTotal := filterFiles(files, "", "", 5, 0)
+
+	// Assert
+	if actualTotal != 2 || len(actual) != 0 {
+		t.Fatalf("Expected an empty page out of 2 files, but got %+v out of %v", actual, actualTotal)
+	}
+}
+
+func Test_ValidateGlob_InvalidPattern(t *testing.T) {
+	// Act
+	err := validateGlob("work/[bank")
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected a validation error, but didn't get it")
+	}
+}
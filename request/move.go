@@ -0,0 +1,255 @@
+package request
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/backend"
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/helpers"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+func movePasswordFiles(request *request) {
+	responseData := response.MakeMoveResponse()
+
+	store := getRequestedStore(request, request.StoreID, "move")
+	crypto := getBackend(request, store, "move")
+
+	source := strings.TrimSuffix(filepath.ToSlash(request.File), "/")
+	destination := strings.TrimSuffix(filepath.ToSlash(request.Destination), "/")
+	if source == "" || destination == "" || source == destination || strings.HasPrefix(destination, source+"/") {
+		log.Errorf("Unable to move '%v' to '%v' in the password store '%+v'", source, destination, store)
+		sendMoveError(
+			fmt.Errorf("Invalid destination '%s' for '%s'", destination, source),
+			"The entry can not be moved to the given destination", store, source,
+		)
+	}
+
+	if strings.HasSuffix(source, passwordFileExtension(store)) {
+		requirePasswordFileExtension(store, destination, "move")
+		requireFileVersion(store, source, request.IfMatch, false, "move")
+		requireFileVersion(store, destination, "", !request.Force, "move")
+
+		responseData.Files = append(responseData.Files, movePasswordFile(store, source, destination, crypto))
+	} else {
+		responseData.Files = moveDirectory(store, source, destination, crypto)
+	}
+
+	removeEmptyParentDirectories(store, filepath.Join(store.Path, source), "move")
+
+	responseData.Commit = commitPasswordFiles(
+		store, []string{source, destination},
+		fmt.Sprintf("Rename %s to %s.", entryName(store, source), entryName(store, destination)), "move",
+	)
+
+	response.SendOk(responseData)
+}
+
+// movePasswordFile moves a single password file, it is only re-encrypted
+// if the recipients at the destination differ from the recipients at the source
+func movePasswordFile(store store, from string, to string, crypto backend.Backend) response.MovedFile {
+	fromPath := filepath.Join(store.Path, from)
+	toPath := filepath.Join(store.Path, to)
+
+	if _, err := os.Stat(fromPath); err != nil {
+		log.Errorf("Unable to find the password file '%v' in the password store '%+v': %+v", from, store, err)
+		sendMoveError(err, "The password file to move does not exist", store, from)
+	}
+
+	moved := response.MovedFile{From: from, To: to}
+	moved.Reencrypted = !sameRecipients(
+		listRecipients(store, from, crypto, "move"),
+		listRecipients(store, to, crypto, "move"),
+	)
+
+	// An overwritten password file can still be restored from the history
+	archivePasswordFile(store, to, "move")
+
+	var err error
+	if moved.Reencrypted {
+		contents, _ := decryptPasswordFile(store, from, crypto, "move")
+		encryptPasswordFile(store, to, contents, crypto, "move")
+		helpers.WipeBytes(contents)
+		err = os.Remove(fromPath)
+	} else {
+		err = os.MkdirAll(filepath.Dir(toPath), 0755)
+		if err == nil {
+			err = os.Rename(fromPath, toPath)
+		}
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to move the password file '%v' to '%v' in the password store '%+v': %+v",
+			from, to, store, err,
+		)
+		sendMoveError(err, "Unable to move the password file", store, from)
+	}
+
+	moveHistory(store, from, to, "move")
+	return moved
+}
+
+// moveDirectory moves a whole directory, including the recipients files and other files it contains.
+// The recipients files are copied first and the password files whose recipients change are re-encrypted
+// into the destination, the source is only removed once all of them succeeded.
+func moveDirectory(store store, from string, to string, crypto backend.Backend) []response.MovedFile {
+	fromPath := filepath.Join(store.Path, from)
+	toPath := filepath.Join(store.Path, to)
+
+	if fi, err := os.Stat(fromPath); err != nil || !fi.IsDir() {
+		if err == nil {
+			err = fmt.Errorf("'%s' is not a directory", from)
+		}
+		log.Errorf("Unable to find the directory '%v' in the password store '%+v': %+v", from, store, err)
+		sendMoveError(err, "The directory to move does not exist", store, from)
+	}
+
+	files := listPasswordFiles(store, from, "move")
+	moved := []response.MovedFile{}
+
+	// Merging into an existing directory could not be undone if it failed halfway, and the recipients files
+	// of both directories would conflict, so it is refused even with force
+	if _, err := os.Stat(toPath); err == nil {
+		log.Errorf("The destination '%v' already exists in the password store '%+v'", to, store)
+		response.SendErrorAndExit(
+			errors.CodePasswordFileConflict,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "The destination directory already exists",
+				errors.FieldAction:    "move",
+				errors.FieldDirectory: to,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+
+	// The password files are compared with the recipients they will have at the destination,
+	// which may come from the recipients files inside the moved directory
+	if err := copyRecipientsFiles(store, from, store, to); err != nil {
+		log.Errorf(
+			"Unable to copy the recipients files of the directory '%v' to '%v' in the password store '%+v': %+v",
+			from, to, store, err,
+		)
+		sendMoveError(err, "Unable to copy the recipients files of the directory", store, from)
+	}
+
+	for _, file := range files {
+		entry := response.MovedFile{From: file, To: to + strings.TrimPrefix(file, from)}
+		entry.Reencrypted = !sameRecipients(
+			listRecipients(store, file, crypto, "move"),
+			listRecipients(store, entry.To, crypto, "move"),
+		)
+		if entry.Reencrypted {
+			contents, _ := decryptPasswordFile(store, file, crypto, "move")
+			encryptPasswordFile(store, entry.To, contents, crypto, "move")
+			helpers.WipeBytes(contents)
+		}
+		moved = append(moved, entry)
+	}
+
+	// Everything that is not at the destination yet is renamed into it, and only then the source is removed
+	err := filepath.WalkDir(fromPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(toPath, strings.TrimPrefix(path, fromPath))
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if _, err := os.Lstat(target); err == nil {
+			return nil
+		}
+		return os.Rename(path, target)
+	})
+	if err == nil {
+		err = os.RemoveAll(fromPath)
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to move the directory '%v' to '%v' in the password store '%+v': %+v",
+			from, to, store, err,
+		)
+		sendMoveError(err, "Unable to move the directory", store, from)
+	}
+
+	for _, entry := range moved {
+		moveHistory(store, entry.From, entry.To, "move")
+	}
+	return moved
+}
+
+// listPasswordFiles lists the password files in a directory of a password store and its subdirectories
+func listPasswordFiles(store store, dir string, action string) []string {
+	walker, err := newStoreWalker(store)
+	files := []string{}
+	if err == nil {
+		err = walker.walkDirectory(dir, func(relativePath string, isDir bool) error {
+			if !isDir {
+				files = append(files, relativePath)
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to list the files in the directory '%v' of the password store '%+v': %+v",
+			dir, store, err,
+		)
+		response.SendErrorAndExit(
+			errors.CodeUnableToListFilesInPasswordStore,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "Unable to list the files in the password store",
+				errors.FieldAction:    action,
+				errors.FieldError:     err.Error(),
+				errors.FieldDirectory: dir,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// sameRecipients checks whether two lists contain the same recipients, regardless of their order
+func sameRecipients(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sendMoveError(err error, message string, store store, file string) {
+	response.SendErrorAndExit(
+		errors.CodeUnableToMovePasswordFile,
+		&map[errors.Field]string{
+			errors.FieldMessage:   message,
+			errors.FieldAction:    "move",
+			errors.FieldError:     err.Error(),
+			errors.FieldFile:      file,
+			errors.FieldStoreID:   store.ID,
+			errors.FieldStoreName: store.Name,
+			errors.FieldStorePath: store.Path,
+		},
+	)
+}
@@ -0,0 +1,176 @@
+package request
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/browserpass/browserpass-native/v3/backend"
+	"github.com/browserpass/browserpass-native/v3/errors"
+)
+
+// addTestAgeRecipient governs a directory of the test store by a new identity,
+// which is also added to the identities of the store
+func addTestAgeRecipient(t *testing.T, store store, dir string) *age.X25519Identity {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Unable to generate a test identity: %v", err)
+	}
+
+	identities, err := os.OpenFile(store.Settings.IdentitiesPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err == nil {
+		_, err = identities.WriteString(identity.String() + "\n")
+		identities.Close()
+	}
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(store.Path, dir), 0755)
+	}
+	if err != nil {
+		t.Fatalf("Unable to add a test identity: %v", err)
+	}
+	writeTestFile(t, store.Path, dir+"/.age-recipients", identity.Recipient().String()+"\n")
+	return identity
+}
+
+func Test_SameRecipients_IgnoresOrder(t *testing.T) {
+	// Arrange
+	a := []string{"alice@example.com", "bob@example.com"}
+	b := []string{"bob@example.com", "alice@example.com"}
+
+	// Act
+	actual := sameRecipients(a, b)
+
+	// Assert
+	if !actual {
+		t.Fatalf("Expected %v and %v to be the same recipients", a, b)
+	}
+
+	if a[0] != "alice@example.com" || b[0] != "bob@example.com" {
+		t.Fatalf("The recipient lists must not be reordered, but got %v and %v", a, b)
+	}
+}
+
+func Test_MoveDirectory_KeepsRecipientsFiles(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, ".gpg-id", "work/.gpg-id", "work/email.gpg", "work/servers/db.gpg")
+	writeTestFile(t, storePath, ".gpg-id", "personal@example.com\n")
+	writeTestFile(t, storePath, "work/.gpg-id", "team@example.com\n")
+	store := store{Path: storePath}
+
+	// Act
+	moved := moveDirectory(store, "work", "archive/work", backend.NewGpg("gpg"))
+
+	// Assert
+	if len(moved) != 2 || moved[0].To != "archive/work/email.gpg" || moved[1].To != "archive/work/servers/db.gpg" {
+		t.Fatalf("Unexpected moved files: %+v", moved)
+	}
+
+	for _, file := range moved {
+		if file.Reencrypted {
+			t.Fatalf("The file '%v' is governed by the moved `.gpg-id`, it must not be re-encrypted", file.To)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(storePath, "archive", "work", ".gpg-id")); err != nil {
+		t.Fatalf("The `.gpg-id` file must be moved along with the directory: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(storePath, "work")); !os.IsNotExist(err) {
+		t.Fatalf("The source directory must not exist anymore, but got: %v", err)
+	}
+}
+
+func Test_MoveDirectory_ReencryptsForDestinationRecipients(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"personal/site.age": "hunter2\n"})
+	addTestAgeRecipient(t, store, "team")
+
+	// Act
+	moved := moveDirectory(store, "personal", "team/personal", backend.NewAge(store.Settings.IdentitiesPath))
+
+	// Assert
+	if len(moved) != 1 || moved[0].To != "team/personal/site.age" || !moved[0].Reencrypted {
+		t.Fatalf("Unexpected moved files: %+v", moved)
+	}
+
+	if actual := readTestAgeFile(t, store, "team/personal/site.age"); actual != "hunter2\n" {
+		t.Fatalf("The moved password file has unexpected contents '%s'", actual)
+	}
+
+	if _, err := os.Stat(filepath.Join(store.Path, "personal")); !os.IsNotExist(err) {
+		t.Fatalf("The source directory must not exist anymore, but got: %v", err)
+	}
+}
+
+func Test_MoveDirectory_FailedReencryptionKeepsSource(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"personal/site.age": "hunter2\n"})
+	writeTestFile(t, store.Path, "personal/zz-broken.age", "not an age file")
+	addTestAgeRecipient(t, store, "team")
+
+	// Act
+	code := recoverErrorCode(t, func() {
+		moveDirectory(store, "personal", "team/personal", backend.NewAge(store.Settings.IdentitiesPath))
+	})
+
+	// Assert
+	if code == 0 {
+		t.Fatalf("Expected the move to fail on the broken password file")
+	}
+
+	if actual := readTestAgeFile(t, store, "personal/site.age"); actual != "hunter2\n" {
+		t.Fatalf("The source password file must be kept, but it has contents '%s'", actual)
+	}
+
+	if _, err := os.Stat(filepath.Join(store.Path, "personal", "zz-broken.age")); err != nil {
+		t.Fatalf("The password file that failed to move must be kept: %v", err)
+	}
+}
+
+func Test_MovePasswordFile_ArchivesOverwrittenFile(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"old.age": "old\n", "new.age": "new\n"})
+
+	// Act
+	movePasswordFile(store, "new.age", "old.age", backend.NewAge(store.Settings.IdentitiesPath))
+
+	// Assert
+	if actual := readTestAgeFile(t, store, "old.age"); actual != "new\n" {
+		t.Fatalf("The destination must be overwritten, but it has contents '%s'", actual)
+	}
+
+	timestamps, err := listHistory(store, "old.age")
+	if err != nil || len(timestamps) != 1 {
+		t.Fatalf("Expected the overwritten password file in the history, but got %v: %v", timestamps, err)
+	}
+
+	archived := historyDirectory + "/old/" + timestamps[0] + ".age"
+	if actual := readTestAgeFile(t, store, archived); actual != "old\n" {
+		t.Fatalf("The archived password file has unexpected contents '%s'", actual)
+	}
+}
+
+func Test_MovePasswordFiles_RefusesToMergeDirectories(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"personal/site.age": "mine\n", "team/site.age": "theirs\n"})
+	request := makeTestRequest("move", store, "personal")
+	request.Destination = "team"
+	request.Force = true
+
+	// Act
+	actual := handleTestRequest(t, request)
+
+	// Assert
+	if actual["status"] != "error" || actual["code"] != float64(errors.CodePasswordFileConflict) {
+		t.Fatalf("Expected the move to be refused, but got %+v", actual)
+	}
+
+	if contents := readTestAgeFile(t, store, "personal/site.age"); contents != "mine\n" {
+		t.Fatalf("The source must be left alone, but it has contents '%s'", contents)
+	}
+
+	if contents := readTestAgeFile(t, store, "team/site.age"); contents != "theirs\n" {
+		t.Fatalf("The destination must be left alone, but it has contents '%s'", contents)
+	}
+}
@@ -0,0 +1,81 @@
+package request
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/browserpass/browserpass-native/v3/entry"
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/otp"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+func generateOtp(request *request) {
+	responseData := response.MakeOtpResponse()
+
+	store := getRequestedStore(request, request.StoreID, "otp")
+	requirePasswordFileExtension(store, request.File, "otp")
+	crypto := getBackend(request, store, "otp")
+
+	decrypted, _ := decryptPasswordFile(store, request.File, crypto, "otp")
+	contents := string(decrypted)
+
+	parsed := entry.Parse(contents, request.File, store.Settings.FieldAliases)
+	if len(parsed.OTP) == 0 {
+		log.Errorf("The password file '%v' in the password store '%+v' does not contain an OTP", request.File, store)
+		response.SendErrorAndExit(
+			errors.CodeNoOtpInPasswordFile,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "The password file does not contain an OTP",
+				errors.FieldAction:    "otp",
+				errors.FieldFile:      request.File,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+
+	uri := parsed.OTP[0]
+	key, err := otp.Parse(uri)
+	if err != nil {
+		log.Errorf(
+			"Unable to parse the OTP parameters of the password file '%v' in the password store '%+v': %+v",
+			request.File, store, err,
+		)
+		response.SendErrorAndExit(
+			errors.CodeInvalidOtpParameters,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "Unable to parse the OTP parameters of the password file",
+				errors.FieldAction:    "otp",
+				errors.FieldError:     err.Error(),
+				errors.FieldFile:      request.File,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+
+	responseData.Type = key.Type
+	if key.Type == otp.TypeTOTP {
+		responseData.Code, responseData.Remaining = key.TOTP(time.Now())
+		responseData.Period = key.Period
+	} else {
+		// Same as pass-otp: increment the counter first, then generate the code for the new value
+		responseData.Counter = key.Counter + 1
+		responseData.Code = key.Code(responseData.Counter)
+
+		contents = strings.Replace(contents, uri, otp.SetCounter(uri, responseData.Counter), 1)
+		archivePasswordFile(store, request.File, "otp")
+		encryptPasswordFile(store, request.File, []byte(contents), crypto, "otp")
+		responseData.Commit = commitPasswordFiles(
+			store, []string{request.File},
+			fmt.Sprintf("Increment HOTP counter for %s.", entryName(store, request.File)), "otp",
+		)
+	}
+
+	response.SendOk(responseData)
+}
@@ -0,0 +1,140 @@
+package request
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/browserpass/browserpass-native/v3/backend"
+)
+
+// makeTestAgeStore creates a passage-compatible store with the given password files encrypted for a new identity
+func makeTestAgeStore(t *testing.T, entries map[string]string) store {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Unable to generate a test identity: %v", err)
+	}
+
+	identitiesPath := filepath.Join(t.TempDir(), "identities")
+	if err := os.WriteFile(identitiesPath, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatalf("Unable to write the test identities: %v", err)
+	}
+
+	store := store{
+		ID:       "test",
+		Path:     makeTestStore(t, ".age-recipients"),
+		Settings: StoreSettings{Backend: backend.NameAge, IdentitiesPath: identitiesPath},
+	}
+	writeTestFile(t, store.Path, ".age-recipients", identity.Recipient().String()+"\n")
+
+	for file, contents := range entries {
+		ciphertext, err := backend.NewAge(identitiesPath).Encrypt([]byte(contents), []string{identity.Recipient().String()})
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(filepath.Join(store.Path, file)), 0755)
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(store.Path, file), ciphertext, 0644)
+		}
+		if err != nil {
+			t.Fatalf("Unable to create a password file for the test store: %v", err)
+		}
+	}
+	return store
+}
+
+// makeTestRequest creates a request for a password file in the store, which is the only configured store
+func makeTestRequest(action string, testStore store, file string) *request {
+	return &request{
+		Action:   action,
+		File:     file,
+		StoreID:  testStore.ID,
+		Settings: settings{Stores: map[string]store{testStore.ID: testStore}},
+	}
+}
+
+// readTestAgeFile decrypts a password file of a store created by makeTestAgeStore
+func readTestAgeFile(t *testing.T, store store, file string) string {
+	ciphertext, err := os.ReadFile(filepath.Join(store.Path, file))
+	var contents []byte
+	if err == nil {
+		contents, _, err = backend.NewAge(store.Settings.IdentitiesPath).Decrypt(ciphertext)
+	}
+	if err != nil {
+		t.Fatalf("Unable to decrypt the password file '%v': %v", file, err)
+	}
+	return string(contents)
+}
+
+func Test_GenerateOtp_TOTP(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{
+		"site.age": "hunter2\notpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&period=30\n",
+	})
+
+	// Act
+	actual := handleTestRequest(t, makeTestRequest("otp", store, "site.age"))
+
+	// Assert
+	data, _ := actual["data"].(map[string]interface{})
+	if actual["status"] != "ok" || data["type"] != "totp" || data["period"] != float64(30) {
+		t.Fatalf("Expected a TOTP code, but got %+v", actual)
+	}
+
+	if code, _ := data["code"].(string); len(code) != 6 {
+		t.Fatalf("Expected a 6 digit code, but got '%v'", data["code"])
+	}
+
+	if remaining, _ := data["remaining"].(float64); remaining < 1 || remaining > 30 {
+		t.Fatalf("Expected the code to remain valid for up to 30 seconds, but got %v", data["remaining"])
+	}
+}
+
+func Test_GenerateOtp_HOTPIncrementsCounter(t *testing.T) {
+	// Arrange
+	// The secret is the ASCII seed of the RFC 4226 test vectors
+	store := makeTestAgeStore(t, map[string]string{
+		"site.age": "hunter2\nlogin: alice\notpauth://hotp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=1\n",
+	})
+
+	// Act
+	actual := handleTestRequest(t, makeTestRequest("otp", store, "site.age"))
+
+	// Assert
+	data, _ := actual["data"].(map[string]interface{})
+	if actual["status"] != "ok" || data["type"] != "hotp" || data["counter"] != float64(2) || data["code"] != "359152" {
+		t.Fatalf("Expected the code for the incremented counter 2, but got %+v", actual)
+	}
+
+	expected := "hunter2\nlogin: alice\notpauth://hotp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=2\n"
+	if contents := readTestAgeFile(t, store, "site.age"); contents != expected {
+		t.Fatalf("Expected the incremented counter to be saved, but the password file contains '%v'", contents)
+	}
+}
+
+func Test_GenerateOtp_HOTPCommitsCounter(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{
+		"site.age": "hunter2\notpauth://hotp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=1\n",
+	})
+	initTestGitStore(t, store)
+
+	// Act
+	actual := handleTestRequest(t, makeTestRequest("otp", store, "site.age"))
+
+	// Assert
+	data, _ := actual["data"].(map[string]interface{})
+	if actual["status"] != "ok" || data["commit"] == nil {
+		t.Fatalf("Expected the incremented counter to be committed, but got %+v", actual)
+	}
+
+	message, err := runGit(store, "log", "-1", "--format=%s")
+	if err != nil || message != "Increment HOTP counter for site." {
+		t.Fatalf("Expected the pass-otp commit message, but got '%v' (%v)", message, err)
+	}
+
+	status, err := runGit(store, "status", "--porcelain")
+	if err != nil || status != "" {
+		t.Fatalf("Expected the git store to be clean, but got '%v' (%v)", status, err)
+	}
+}
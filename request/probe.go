@@ -0,0 +1,141 @@
+package request
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/backend"
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/helpers"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+func probeStores(request *request) {
+	responseData := response.MakeProbeResponse()
+
+	if request.File != "" {
+		store := getRequestedStore(request, request.StoreID, "probe")
+		requirePasswordFileExtension(store, request.File, "probe")
+		crypto := getBackend(request, store, "probe")
+
+		responseData.State = probePasswordFile(store, request.File, crypto)
+		response.SendOk(responseData)
+		return
+	}
+
+	storeIDs := []string{}
+	if request.StoreID != "" {
+		storeIDs = append(storeIDs, request.StoreID)
+	} else {
+		for storeID := range request.Settings.Stores {
+			storeIDs = append(storeIDs, storeID)
+		}
+		sort.Strings(storeIDs)
+	}
+
+	for _, storeID := range storeIDs {
+		store := getRequestedStore(request, storeID, "probe")
+
+		index, err := indexStore(store)
+		if err != nil {
+			log.Errorf(
+				"Unable to list the files in the password store '%+v' at its location: %+v",
+				store, err,
+			)
+			response.SendErrorAndExit(
+				errors.CodeUnableToListFilesInPasswordStore,
+				&map[errors.Field]string{
+					errors.FieldMessage:   "Unable to list the files in the password store",
+					errors.FieldAction:    "probe",
+					errors.FieldError:     err.Error(),
+					errors.FieldStoreID:   store.ID,
+					errors.FieldStoreName: store.Name,
+					errors.FieldStorePath: store.Path,
+				},
+			)
+		}
+
+		// A store without password files has nothing to unlock
+		if len(index.Files) == 0 {
+			continue
+		}
+
+		// Entries governed by different recipients files may be encrypted for different keys,
+		// so the first password file of every directory with its own recipients file is probed
+		crypto := getBackend(request, store, "probe")
+		states := make(map[string]string)
+		for _, file := range index.Files {
+			dir := recipientsDirectory(store, file)
+			if _, ok := states[dir]; !ok {
+				states[dir] = probePasswordFile(store, file, crypto)
+			}
+		}
+		responseData.Stores[store.ID] = combineProbeStates(states)
+		responseData.Directories[store.ID] = states
+	}
+
+	response.SendOk(responseData)
+}
+
+// probePasswordFile checks whether the password file can be decrypted without prompting for a passphrase
+func probePasswordFile(store store, file string, crypto backend.Backend) string {
+	ciphertext, err := os.ReadFile(filepath.Join(store.Path, file))
+	var state string
+	if err == nil {
+		state, err = crypto.Probe(ciphertext)
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to probe the password file '%v' in the password store '%+v': %+v",
+			file, store, err,
+		)
+		sendBackendFailure(err, store, file, "probe")
+		response.SendErrorAndExit(
+			errors.CodeUnableToDecryptPasswordFile,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "Unable to probe the password file",
+				errors.FieldAction:    "probe",
+				errors.FieldError:     err.Error(),
+				errors.FieldFile:      file,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+
+	return state
+}
+
+// recipientsDirectory returns the directory of the recipients file governing the password file,
+// or the store root if there is none in the store
+func recipientsDirectory(store store, file string) string {
+	recipientsPath, err := helpers.FindRecipientsFile(filepath.Join(store.Path, file), recipientsFileName(store))
+	if err != nil {
+		return ""
+	}
+
+	dir, err := filepath.Rel(store.Path, filepath.Dir(recipientsPath))
+	if err != nil || dir == "." || strings.HasPrefix(dir, "..") {
+		return ""
+	}
+	return filepath.ToSlash(dir)
+}
+
+// combineProbeStates returns the state of a whole store: locked if unlocking would make any part of it
+// available, otherwise unlocked if any part of it is, and no-secret-key if none of it can be decrypted
+func combineProbeStates(states map[string]string) string {
+	combined := backend.ProbeNoSecretKey
+	for _, state := range states {
+		switch state {
+		case backend.ProbeLocked:
+			return backend.ProbeLocked
+		case backend.ProbeUnlocked:
+			combined = backend.ProbeUnlocked
+		}
+	}
+	return combined
+}
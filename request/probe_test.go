@@ -0,0 +1,72 @@
+package request
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/browserpass/browserpass-native/v3/backend"
+)
+
+func Test_ProbeStores_ProbesEveryRecipientsFile(t *testing.T) {
+	// Arrange
+	store := makeTestAgeStore(t, map[string]string{"site.age": "hunter2\n"})
+	foreign, err := age.GenerateX25519Identity()
+	var ciphertext []byte
+	if err == nil {
+		ciphertext, err = backend.NewAge(store.Settings.IdentitiesPath).Encrypt(
+			[]byte("hunter2\n"), []string{foreign.Recipient().String()},
+		)
+	}
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(store.Path, "team"), 0755)
+	}
+	if err != nil {
+		t.Fatalf("Unable to create a password file for a foreign identity: %v", err)
+	}
+	writeTestFile(t, store.Path, "team/.age-recipients", foreign.Recipient().String()+"\n")
+	writeTestFile(t, store.Path, "team/site.age", string(ciphertext))
+	request := makeTestRequest("probe", store, "")
+
+	// Act
+	actual := handleTestRequest(t, request)
+
+	// Assert
+	data, ok := actual["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected the probe results, but got %+v", actual)
+	}
+
+	expected := map[string]interface{}{"": backend.ProbeUnlocked, "team": backend.ProbeNoSecretKey}
+	directories := data["directories"].(map[string]interface{})
+	if !reflect.DeepEqual(directories[store.ID], expected) {
+		t.Fatalf("Expected every directory with a recipients file to be probed, but got %+v", directories)
+	}
+
+	if stores := data["stores"].(map[string]interface{}); stores[store.ID] != backend.ProbeUnlocked {
+		t.Fatalf("Expected the store to be unlocked, but got %+v", stores)
+	}
+}
+
+func Test_CombineProbeStates(t *testing.T) {
+	tests := []struct {
+		states   map[string]string
+		expected string
+	}{
+		{map[string]string{"": backend.ProbeUnlocked, "team": backend.ProbeLocked}, backend.ProbeLocked},
+		{map[string]string{"": backend.ProbeNoSecretKey, "team": backend.ProbeUnlocked}, backend.ProbeUnlocked},
+		{map[string]string{"": backend.ProbeNoSecretKey}, backend.ProbeNoSecretKey},
+	}
+
+	for _, test := range tests {
+		// Act
+		actual := combineProbeStates(test.states)
+
+		// Assert
+		if actual != test.expected {
+			t.Fatalf("Expected the state '%v' for %v, but got '%v'", test.expected, test.states, actual)
+		}
+	}
+}
@@ -6,13 +6,21 @@ import (
 	"io"
 	"os"
 
+	"github.com/browserpass/browserpass-native/v3/entry"
 	"github.com/browserpass/browserpass-native/v3/errors"
 	"github.com/browserpass/browserpass-native/v3/response"
 	log "github.com/sirupsen/logrus"
 )
 
 type StoreSettings struct {
-	GpgPath string `json:"gpgPath"`
+	GpgPath        string              `json:"gpgPath"`
+	Backend        string              `json:"backend"`
+	KeyringPath    string              `json:"keyringPath"`
+	IdentitiesPath string              `json:"identitiesPath"`
+	FollowSymlinks string              `json:"followSymlinks"`
+	FieldAliases   map[string][]string `json:"fieldAliases"`
+	SigningKeys    []string            `json:"signingKeys"`
+	HistorySize    *int                `json:"historySize"`
 }
 
 type store struct {
@@ -20,6 +28,9 @@ type store struct {
 	Name     string        `json:"name"`
 	Path     string        `json:"path"`
 	Settings StoreSettings `json:"settings"`
+
+	// backendName the encryption backend of the store, determined once when the store is resolved
+	backendName string
 }
 
 type settings struct {
@@ -28,18 +39,73 @@ type settings struct {
 }
 
 type request struct {
-	Action       string      `json:"action"`
-	Settings     settings    `json:"settings"`
-	File         string      `json:"file"`
-	Contents     string      `json:"contents"`
-	StoreID      string      `json:"storeId"`
-	EchoResponse interface{} `json:"echoResponse"`
+	Action             string          `json:"action"`
+	Settings           settings        `json:"settings"`
+	File               string          `json:"file"`
+	Contents           string          `json:"contents"`
+	StoreID            string          `json:"storeId"`
+	Nested             bool            `json:"nested"`
+	Offset             int             `json:"offset"`
+	Limit              int             `json:"limit"`
+	Prefix             string          `json:"prefix"`
+	Glob               string          `json:"glob"`
+	Parsed             bool            `json:"parsed"`
+	Fields             []string        `json:"fields"`
+	Meta               bool            `json:"meta"`
+	Encoding           string          `json:"encoding"`
+	IfMatch            string          `json:"ifMatch"`
+	IfNoneMatch        bool            `json:"ifNoneMatch"`
+	Pull               bool            `json:"pull"`
+	Push               bool            `json:"push"`
+	Destination        string          `json:"destination"`
+	DestinationStoreID string          `json:"destinationStoreId"`
+	Force              bool            `json:"force"`
+	DryRun             bool            `json:"dryRun"`
+	Policy             json.RawMessage `json:"policy,omitempty"`
+	Save               bool            `json:"save"`
+	Timestamp          string          `json:"timestamp"`
+	Changes            []entry.Change  `json:"changes"`
+	EchoResponse       interface{}     `json:"echoResponse"`
 }
 
 // Process handles browser request
 func Process() {
-	requestLength, err := parseRequestLength(os.Stdin)
+	handleRequest(readRequest(os.Stdin))
+
+	if response.IsSession() {
+		processSession()
+	}
+}
+
+// processSession keeps handling browser requests on a long-lived connection until the browser disconnects
+func processSession() {
+	for {
+		handleSessionRequest(readRequest(os.Stdin))
+	}
+}
+
+func handleSessionRequest(request *request) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(response.AbortedRequest); !ok {
+				panic(r)
+			}
+		}
+	}()
+
+	handleRequest(request)
+}
+
+func readRequest(input io.Reader) *request {
+	requestLength, err := parseRequestLength(input)
+	if err == io.EOF && response.IsSession() {
+		log.Debug("The browser has closed the connection, ending the session")
+		os.Exit(0)
+	}
+
+	// Errors while reading a request are fatal even in session mode, because the message framing is lost
 	if err != nil {
+		response.EndSession()
 		log.Error("Unable to parse the length of the browser request: ", err)
 		response.SendErrorAndExit(
 			errors.CodeParseRequestLength,
@@ -50,8 +116,9 @@ func Process() {
 		)
 	}
 
-	request, err := parseRequest(requestLength, os.Stdin)
+	request, err := parseRequest(requestLength, input)
 	if err != nil {
+		response.EndSession()
 		log.Error("Unable to parse the browser request: ", err)
 		response.SendErrorAndExit(
 			errors.CodeParseRequest,
@@ -62,6 +129,10 @@ func Process() {
 		)
 	}
 
+	return request
+}
+
+func handleRequest(request *request) {
 	switch request.Action {
 	case "configure":
 		configure(request)
@@ -69,12 +140,36 @@ func Process() {
 		listFiles(request)
 	case "tree":
 		listDirectories(request)
+	case "index":
+		indexStores(request)
 	case "fetch":
 		fetchDecryptedContents(request)
+	case "otp":
+		generateOtp(request)
+	case "generate":
+		generatePassword(request)
+	case "probe":
+		probeStores(request)
 	case "save":
 		saveEncryptedContents(request)
+	case "update":
+		updateEntry(request)
 	case "delete":
 		deleteFile(request)
+	case "history":
+		listPasswordFileHistory(request)
+	case "restore":
+		restorePasswordFile(request)
+	case "move":
+		movePasswordFiles(request)
+	case "copy":
+		copyPasswordFiles(request)
+	case "reencrypt":
+		reencryptPasswordFiles(request)
+	case "sync":
+		syncStores(request)
+	case "watch":
+		watchStores(request)
 	case "echo":
 		response.SendRaw(request.EchoResponse)
 	default:
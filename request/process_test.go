@@ -4,10 +4,74 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"os"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/browserpass/browserpass-native/v3/response"
 )
 
+// captureResponses redirects the responses of the host app for the rest of the test,
+// and returns a channel with the decoded responses
+func captureResponses(t *testing.T) <-chan map[string]interface{} {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unable to create a pipe for the responses: %v", err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		writer.Close()
+	})
+
+	responses := make(chan map[string]interface{}, 100)
+	go func() {
+		defer close(responses)
+		defer reader.Close()
+		for {
+			length, err := parseRequestLength(reader)
+			if err != nil {
+				return
+			}
+			message := make([]byte, length)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				return
+			}
+			var decoded map[string]interface{}
+			if err := json.Unmarshal(message, &decoded); err != nil {
+				return
+			}
+			responses <- decoded
+		}
+	}()
+	return responses
+}
+
+// receiveResponse waits for the next response of the host app
+func receiveResponse(t *testing.T, responses <-chan map[string]interface{}) map[string]interface{} {
+	select {
+	case received := <-responses:
+		return received
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected a response, but didn't get any")
+		return nil
+	}
+}
+
+// handleTestRequest handles a request in session mode, so that an error response does not exit the tests,
+// and returns the response
+func handleTestRequest(t *testing.T, request *request) map[string]interface{} {
+	responses := captureResponses(t)
+	response.StartSession()
+	defer response.EndSession()
+
+	handleSessionRequest(request)
+	return receiveResponse(t, responses)
+}
+
 func Test_ParseRequestLength_ConsidersFirstFourBytes(t *testing.T) {
 	// Arrange
 	expected := uint32(201334791) // 0x0c002007
@@ -114,3 +178,29 @@ func Test_ParseRequest_InvalidJson(t *testing.T) {
 		t.Fatalf("Expected a parsing error, but didn't get it")
 	}
 }
+
+func Test_HandleSessionRequest_ErrorDoesNotEndSession(t *testing.T) {
+	// Arrange
+	responses := captureResponses(t)
+	response.StartSession()
+	defer response.EndSession()
+
+	// Act
+	handleSessionRequest(&request{Action: "unknown"})
+	handleSessionRequest(&request{Action: "echo", EchoResponse: map[string]bool{"alive": true}})
+
+	// Assert
+	failed := receiveResponse(t, responses)
+	if failed["status"] != "error" || failed["code"] != float64(12) {
+		t.Fatalf("Expected an invalid action error, but got %+v", failed)
+	}
+
+	echoed := receiveResponse(t, responses)
+	if echoed["alive"] != true {
+		t.Fatalf("Expected the echoed response, but got %+v", echoed)
+	}
+
+	if !response.IsSession() {
+		t.Fatalf("A failed request must not end the session")
+	}
+}
@@ -0,0 +1,131 @@
+package request
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/backend"
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/helpers"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+func reencryptPasswordFiles(request *request) {
+	responseData := response.MakeReencryptResponse()
+
+	store := getRequestedStore(request, request.StoreID, "reencrypt")
+	crypto := getBackend(request, store, "reencrypt")
+
+	dir := strings.Trim(filepath.ToSlash(request.File), "/")
+	files := listPasswordFiles(store, dir, "reencrypt")
+
+	expectedKeyIDs := map[string][]string{}
+	for i, file := range files {
+		stale := needsReencryption(store, file, crypto, expectedKeyIDs)
+		if stale {
+			responseData.Files = append(responseData.Files, file)
+
+			// Every file is replaced atomically, so an interrupted run leaves each file either old or new
+			if !request.DryRun {
+				contents, _ := decryptPasswordFile(store, file, crypto, "reencrypt")
+				encryptPasswordFile(store, file, contents, crypto, "reencrypt")
+				helpers.WipeBytes(contents)
+			}
+		}
+
+		if response.IsSession() {
+			response.SendEvent("reencryptProgress", &response.ReencryptProgressEvent{
+				StoreID: store.ID,
+				File:    file,
+				Stale:   stale,
+				Done:    i + 1,
+				Total:   len(files),
+			})
+		}
+	}
+	responseData.Checked = len(files)
+
+	if !request.DryRun && len(responseData.Files) > 0 {
+		message := "Reencrypt password store using browserpass."
+		if dir != "" {
+			message = fmt.Sprintf("Reencrypt password store using browserpass (%s).", dir)
+		}
+		responseData.Commit = commitPasswordFiles(store, responseData.Files, message, "reencrypt")
+	}
+
+	response.SendOk(responseData)
+}
+
+// needsReencryption checks whether a password file is encrypted for other keys than its recipients resolve to now.
+// The keys for a set of recipients are determined by encrypting an empty message for them, so that exactly
+// the (sub)keys the backend would pick are compared, and they are cached in expectedKeyIDs.
+func needsReencryption(store store, file string, crypto backend.Backend, expectedKeyIDs map[string][]string) bool {
+	recipients := listRecipients(store, file, crypto, "reencrypt")
+
+	ciphertext, err := os.ReadFile(filepath.Join(store.Path, file))
+	var actual []string
+	if err == nil {
+		actual, err = crypto.EncryptedFor(ciphertext)
+	}
+	if err == backend.ErrHiddenRecipients {
+		// There is no way to tell, so the file is always re-encrypted, same as passage does
+		return true
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to determine the keys the password file '%v' in the password store '%+v' is encrypted for: %+v",
+			file, store, err,
+		)
+		sendBackendFailure(err, store, file, "reencrypt")
+		response.SendErrorAndExit(
+			errors.CodeUnableToDecryptPasswordFile,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "Unable to determine the keys the password file is encrypted for",
+				errors.FieldAction:    "reencrypt",
+				errors.FieldError:     err.Error(),
+				errors.FieldFile:      file,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+
+	sorted := append([]string{}, recipients...)
+	sort.Strings(sorted)
+	cacheKey := strings.Join(sorted, "\n")
+
+	expected, ok := expectedKeyIDs[cacheKey]
+	if !ok {
+		ciphertext, err := crypto.Encrypt([]byte{}, recipients)
+		if err == nil {
+			expected, err = crypto.EncryptedFor(ciphertext)
+		}
+		if err != nil {
+			log.Errorf(
+				"Unable to determine the keys of the recipients '%v' in the password store '%+v': %+v",
+				recipients, store, err,
+			)
+			sendBackendFailure(err, store, file, "reencrypt")
+			response.SendErrorAndExit(
+				errors.CodeUnableToEncryptPasswordFile,
+				&map[errors.Field]string{
+					errors.FieldMessage:   "Unable to determine the keys of the recipients",
+					errors.FieldAction:    "reencrypt",
+					errors.FieldError:     err.Error(),
+					errors.FieldFile:      file,
+					errors.FieldStoreID:   store.ID,
+					errors.FieldStoreName: store.Name,
+					errors.FieldStorePath: store.Path,
+				},
+			)
+		}
+		expectedKeyIDs[cacheKey] = expected
+	}
+
+	return !sameRecipients(actual, expected)
+}
@@ -0,0 +1,115 @@
+package request
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/browserpass/browserpass-native/v3/backend"
+)
+
+// makeTestRecipientKey generates a key created an hour ago, so that a subkey added later is always the newest one
+func makeTestRecipientKey(t *testing.T, email string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity("Test User", "", email, &packet.Config{
+		Algorithm: packet.PubKeyAlgoEdDSA,
+		Time:      func() time.Time { return time.Now().Add(-time.Hour) },
+	})
+	if err != nil {
+		t.Fatalf("Unable to generate a test key: %v", err)
+	}
+	return entity
+}
+
+func Test_NeedsReencryption(t *testing.T) {
+	tests := []struct {
+		name         string
+		encryptedFor []string
+		recipients   string
+		newSubkey    bool
+		expected     bool
+	}{
+		{
+			name:         "same recipients",
+			encryptedFor: []string{"alice@example.com", "bob@example.com"},
+			recipients:   "bob@example.com\nalice@example.com\n",
+			expected:     false,
+		},
+		{
+			name:         "added recipient",
+			encryptedFor: []string{"alice@example.com"},
+			recipients:   "alice@example.com\nbob@example.com\n",
+			expected:     true,
+		},
+		{
+			name:         "removed recipient",
+			encryptedFor: []string{"alice@example.com", "bob@example.com"},
+			recipients:   "alice@example.com\n",
+			expected:     true,
+		},
+		{
+			name:         "new encryption subkey only",
+			encryptedFor: []string{"alice@example.com"},
+			recipients:   "alice@example.com\n",
+			newSubkey:    true,
+			expected:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			keys := map[string]*openpgp.Entity{
+				"alice@example.com": makeTestRecipientKey(t, "alice@example.com"),
+				"bob@example.com":   makeTestRecipientKey(t, "bob@example.com"),
+			}
+
+			storePath := makeTestStore(t, ".gpg-id")
+			writeTestFile(t, storePath, ".gpg-id", tt.recipients)
+
+			entities := []*openpgp.Entity{}
+			for _, email := range tt.encryptedFor {
+				entities = append(entities, keys[email])
+			}
+			var ciphertext bytes.Buffer
+			writer, err := openpgp.Encrypt(&ciphertext, entities, nil, nil, nil)
+			if err == nil {
+				err = writer.Close()
+			}
+			if err != nil {
+				t.Fatalf("Unable to encrypt the test password file: %v", err)
+			}
+			writeTestFile(t, storePath, "site.gpg", ciphertext.String())
+
+			if tt.newSubkey {
+				if err := keys["alice@example.com"].AddEncryptionSubkey(nil); err != nil {
+					t.Fatalf("Unable to add a test subkey: %v", err)
+				}
+			}
+
+			var keyring bytes.Buffer
+			for _, entity := range keys {
+				if err := entity.SerializePrivateWithoutSigning(&keyring, nil); err != nil {
+					t.Fatalf("Unable to serialize the test keyring: %v", err)
+				}
+			}
+			keyringPath := filepath.Join(t.TempDir(), "keyring.gpg")
+			if err := os.WriteFile(keyringPath, keyring.Bytes(), 0600); err != nil {
+				t.Fatalf("Unable to write the test keyring: %v", err)
+			}
+
+			// Act
+			actual := needsReencryption(
+				store{Path: storePath}, "site.gpg", backend.NewOpenPGP(keyringPath), map[string][]string{},
+			)
+
+			// Assert
+			if actual != tt.expected {
+				t.Fatalf("Expected needsReencryption to be %v, but got %v", tt.expected, actual)
+			}
+		})
+	}
+}
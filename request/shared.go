@@ -0,0 +1,556 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/backend"
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/helpers"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+// requirePasswordFileExtension sends an error response if the requested file is not a password file of the store
+func requirePasswordFileExtension(store store, file string, action string) {
+	extension := passwordFileExtension(store)
+	if !strings.HasSuffix(file, extension) {
+		log.Errorf("The requested password file '%v' does not have the expected '%v' extension", file, extension)
+		response.SendErrorAndExit(
+			errors.CodeInvalidPasswordFileExtension,
+			&map[errors.Field]string{
+				errors.FieldMessage: fmt.Sprintf("The requested password file does not have the expected '%v' extension", extension),
+				errors.FieldAction:  action,
+				errors.FieldFile:    file,
+			},
+		)
+	}
+}
+
+// getStoreBackendName returns the name of the encryption backend configured for the store,
+// or detects it from the contents of the store if it is not configured.
+// Resolved stores have it cached, so that the store is not scanned again for every password file.
+func getStoreBackendName(store store) string {
+	if store.backendName != "" {
+		return store.backendName
+	}
+	if store.Settings.Backend != "" {
+		return store.Settings.Backend
+	}
+	return detectStoreBackend(store)
+}
+
+// detectStoreBackend detects passage-compatible stores, which have an `.age-recipients` file
+// in the root directory, or contain `*.age` files instead of `*.gpg` files
+func detectStoreBackend(store store) string {
+	if _, err := os.Stat(filepath.Join(store.Path, helpers.AgeRecipientsFile)); err == nil {
+		return backend.NameAge
+	}
+	if _, err := os.Stat(filepath.Join(store.Path, helpers.GpgRecipientsFile)); err == nil {
+		return backend.NameGpg
+	}
+
+	// The store is walked by the same rules as when listing it, until the first password file of either kind
+	detected := backend.NameGpg
+	walker, err := newFileWalker(store, "")
+	if err == nil {
+		walker.walk(func(relativePath string, isDir bool) error {
+			switch {
+			case isDir:
+				return nil
+			case strings.HasSuffix(relativePath, ".age"):
+				detected = backend.NameAge
+			case !strings.HasSuffix(relativePath, ".gpg"):
+				return nil
+			}
+			return filepath.SkipAll
+		})
+	}
+	return detected
+}
+
+// passwordFileExtension returns the extension of the password files in the store
+func passwordFileExtension(store store) string {
+	if getStoreBackendName(store) == backend.NameAge {
+		return ".age"
+	}
+	return ".gpg"
+}
+
+// recipientsFileName returns the name of the files listing the recipients in the store
+func recipientsFileName(store store) string {
+	if getStoreBackendName(store) == backend.NameAge {
+		return helpers.AgeRecipientsFile
+	}
+	return helpers.GpgRecipientsFile
+}
+
+// getRequestedStore finds the store with the requested ID, normalizes its path and determines its backend,
+// sending an error response if the store is unknown or inaccessible
+func getRequestedStore(request *request, storeID string, action string) store {
+	store, ok := request.Settings.Stores[storeID]
+	if !ok {
+		log.Errorf(
+			"The password store with ID '%v' is not present in the list of stores '%+v'",
+			storeID, request.Settings.Stores,
+		)
+		response.SendErrorAndExit(
+			errors.CodeInvalidPasswordStore,
+			&map[errors.Field]string{
+				errors.FieldMessage: "The password store is not present in the list of stores",
+				errors.FieldAction:  action,
+				errors.FieldStoreID: storeID,
+			},
+		)
+	}
+
+	normalizedStorePath, err := normalizePasswordStorePath(store.Path)
+	if err != nil {
+		log.Errorf(
+			"The password store '%+v' is not accessible at its location: %+v",
+			store, err,
+		)
+		response.SendErrorAndExit(
+			errors.CodeInaccessiblePasswordStore,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "The password store is not accessible",
+				errors.FieldAction:    action,
+				errors.FieldError:     err.Error(),
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+	store.Path = normalizedStorePath
+	store.backendName = getStoreBackendName(store)
+
+	return store
+}
+
+// getBackend returns the encryption backend configured for the store,
+// sending an error response if the backend is misconfigured
+func getBackend(request *request, store store, action string) backend.Backend {
+	switch getStoreBackendName(store) {
+	case backend.NameGpg:
+		return backend.NewGpg(getGpgPath(request, store, action))
+	case backend.NameOpenPGP:
+		pgp := backend.NewOpenPGP(expandPath(store.Settings.KeyringPath))
+		if err := pgp.Validate(); err != nil {
+			sendInvalidBackendError(store, action, err)
+		}
+		return pgp
+	case backend.NameAge:
+		identitiesPath := store.Settings.IdentitiesPath
+		if identitiesPath == "" {
+			identitiesPath = getDefaultAgeIdentitiesPath()
+		}
+		age := backend.NewAge(expandPath(identitiesPath))
+		if err := age.Validate(); err != nil {
+			sendInvalidBackendError(store, action, err)
+		}
+		return age
+	default:
+		sendInvalidBackendError(store, action, fmt.Errorf("Unknown encryption backend '%v'", store.Settings.Backend))
+		return nil
+	}
+}
+
+// getDefaultAgeIdentitiesPath returns the location of the identities file used by passage
+func getDefaultAgeIdentitiesPath() string {
+	if path := os.Getenv("PASSAGE_IDENTITIES_FILE"); path != "" {
+		return path
+	}
+	if dir := os.Getenv("PASSAGE_DIR"); dir != "" {
+		return filepath.Join(dir, "identities")
+	}
+	return "~/.passage/identities"
+}
+
+func expandPath(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		path = filepath.Join("$HOME", path[2:])
+	}
+	return os.ExpandEnv(path)
+}
+
+func sendInvalidBackendError(store store, action string, err error) {
+	log.Errorf(
+		"The encryption backend of the password store '%+v' is invalid: %+v",
+		store, err,
+	)
+	response.SendErrorAndExit(
+		errors.CodeInvalidEncryptionBackend,
+		&map[errors.Field]string{
+			errors.FieldMessage:   "The encryption backend of the password store is invalid",
+			errors.FieldAction:    action,
+			errors.FieldError:     err.Error(),
+			errors.FieldStoreID:   store.ID,
+			errors.FieldStoreName: store.Name,
+			errors.FieldStorePath: store.Path,
+		},
+	)
+}
+
+// getGpgPath returns the gpg binary configured by the user (globally or for the store),
+// or detects it automatically, sending an error response if there is no usable gpg binary
+func getGpgPath(request *request, store store, action string) string {
+	var gpgPath string
+	var err error
+	if request.Settings.GpgPath != "" || store.Settings.GpgPath != "" {
+		if request.Settings.GpgPath != "" {
+			gpgPath = request.Settings.GpgPath
+		} else {
+			gpgPath = store.Settings.GpgPath
+		}
+		err = helpers.ValidateGpgBinary(gpgPath)
+		if err != nil {
+			log.Errorf(
+				"The provided gpg binary path '%v' is invalid: %+v",
+				gpgPath, err,
+			)
+			response.SendErrorAndExit(
+				errors.CodeInvalidGpgPath,
+				&map[errors.Field]string{
+					errors.FieldMessage: "The provided gpg binary path is invalid",
+					errors.FieldAction:  action,
+					errors.FieldError:   err.Error(),
+					errors.FieldGpgPath: gpgPath,
+				},
+			)
+		}
+	} else {
+		gpgPath, err = helpers.DetectGpgBinary()
+		if err != nil {
+			log.Error("Unable to detect the location of the gpg binary: ", err)
+			response.SendErrorAndExit(
+				errors.CodeUnableToDetectGpgPath,
+				&map[errors.Field]string{
+					errors.FieldMessage: "Unable to detect the location of the gpg binary",
+					errors.FieldAction:  action,
+					errors.FieldError:   err.Error(),
+				},
+			)
+		}
+	}
+
+	return gpgPath
+}
+
+// decryptPasswordFile decrypts a password file, sending an error response on failure.
+// The metadata describes the keys and the signature of the file. The returned contents can be wiped with helpers.WipeBytes after use.
+func decryptPasswordFile(store store, file string, crypto backend.Backend, action string) ([]byte, *backend.Metadata) {
+	ciphertext, err := os.ReadFile(filepath.Join(store.Path, file))
+	var contents []byte
+	var meta *backend.Metadata
+	if err == nil {
+		contents, meta, err = crypto.Decrypt(ciphertext)
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to decrypt the password file '%v' in the password store '%+v': %+v",
+			file, store, err,
+		)
+		sendBackendFailure(err, store, file, action)
+		response.SendErrorAndExit(
+			errors.CodeUnableToDecryptPasswordFile,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "Unable to decrypt the password file",
+				errors.FieldAction:    action,
+				errors.FieldError:     err.Error(),
+				errors.FieldFile:      file,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+
+	return contents, meta
+}
+
+// encryptPasswordFile encrypts the contents for the recipients governing the password file,
+// and saves the result to the password file, sending an error response on failure
+func encryptPasswordFile(store store, file string, contents []byte, crypto backend.Backend, action string) {
+	filePath := filepath.Join(store.Path, file)
+
+	verifyRecipientsFile(store, file, crypto, action)
+	recipients := listRecipients(store, file, crypto, action)
+
+	ciphertext, err := crypto.Encrypt(contents, recipients)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(filePath), 0755)
+		if err != nil {
+			err = fmt.Errorf("Unable to create directory structure: %s", err.Error())
+		}
+	}
+	if err == nil {
+		err = helpers.WriteFileAtomically(filePath, ciphertext)
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to encrypt the password file '%v' in the password store '%+v': %+v",
+			file, store, err,
+		)
+		sendBackendFailure(err, store, file, action)
+		response.SendErrorAndExit(
+			errors.CodeUnableToEncryptPasswordFile,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "Unable to encrypt the password file",
+				errors.FieldAction:    action,
+				errors.FieldError:     err.Error(),
+				errors.FieldFile:      file,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+}
+
+// listRecipients determines the recipients a password file is (or would be) encrypted for
+func listRecipients(store store, file string, crypto backend.Backend, action string) []string {
+	recipients, err := crypto.ListRecipients(filepath.Join(store.Path, file))
+	if err != nil {
+		log.Error("Unable to determine recipients for the encryption: ", err)
+		response.SendErrorAndExit(
+			errors.CodeUnableToDetermineGpgRecipients,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "Unable to determine recipients for the encryption",
+				errors.FieldAction:    action,
+				errors.FieldError:     err.Error(),
+				errors.FieldFile:      file,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+	return recipients
+}
+
+// copyRecipientsFiles copies the recipients files in a directory of a password store and its subdirectories,
+// along with their signatures, to the same places below a directory of the destination store.
+// Nothing is copied if a recipients file would replace a different recipients file there,
+// as the files already governed by it would not match anymore.
+func copyRecipientsFiles(store store, from string, destinationStore store, to string) error {
+	recipientsFile := recipientsFileName(store)
+	walker, err := newFileWalker(store, recipientsFile)
+	if err != nil {
+		return err
+	}
+
+	files := []string{}
+	err = walker.walkDirectory(from, func(relativePath string, isDir bool) error {
+		if !isDir && path.Base(relativePath) == recipientsFile {
+			files = append(files, relativePath, relativePath+".sig")
+		}
+		return nil
+	})
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	contents := make(map[string][]byte, len(files))
+	for _, file := range files {
+		source, err := os.ReadFile(filepath.Join(store.Path, file))
+		if os.IsNotExist(err) && strings.HasSuffix(file, ".sig") {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		target := to + strings.TrimPrefix(file, from)
+		existing, err := os.ReadFile(filepath.Join(destinationStore.Path, target))
+		if err == nil && !bytes.Equal(existing, source) {
+			return fmt.Errorf("A different recipients file '%s' already exists at the destination", target)
+		}
+		contents[target] = source
+	}
+
+	for target, source := range contents {
+		targetPath := filepath.Join(destinationStore.Path, target)
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return err
+		}
+		if err := helpers.WriteFileAtomically(targetPath, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeEmptyParentDirectories deletes the parent directories of a removed file that became empty,
+// up to the root of the password store
+func removeEmptyParentDirectories(store store, filePath string, action string) {
+	parentDir := filepath.Dir(filePath)
+	for {
+		if parentDir == store.Path {
+			break
+		}
+
+		isEmpty, err := helpers.IsDirectoryEmpty(parentDir)
+		if err != nil {
+			log.Error("Unable to determine if directory is empty and can be deleted: ", err)
+			response.SendErrorAndExit(
+				errors.CodeUnableToDetermineIsDirectoryEmpty,
+				&map[errors.Field]string{
+					errors.FieldMessage:   "Unable to determine if directory is empty and can be deleted",
+					errors.FieldAction:    action,
+					errors.FieldError:     err.Error(),
+					errors.FieldDirectory: parentDir,
+					errors.FieldStoreID:   store.ID,
+					errors.FieldStoreName: store.Name,
+					errors.FieldStorePath: store.Path,
+				},
+			)
+		}
+
+		if !isEmpty {
+			break
+		}
+
+		err = os.Remove(parentDir)
+		if err != nil {
+			log.Error("Unable to delete the empty directory: ", err)
+			response.SendErrorAndExit(
+				errors.CodeUnableToDeleteEmptyDirectory,
+				&map[errors.Field]string{
+					errors.FieldMessage:   "Unable to delete the empty directory",
+					errors.FieldAction:    action,
+					errors.FieldError:     err.Error(),
+					errors.FieldDirectory: parentDir,
+					errors.FieldStoreID:   store.ID,
+					errors.FieldStoreName: store.Name,
+					errors.FieldStorePath: store.Path,
+				},
+			)
+		}
+
+		parentDir = filepath.Dir(parentDir)
+	}
+}
+
+// verifyRecipientsFile requires a valid detached signature `.gpg-id.sig` of the `.gpg-id` file governing
+// the password file, made by one of the allowed signing keys, same as pass with PASSWORD_STORE_SIGNING_KEY.
+// Nothing is verified if no signing keys are configured, or for stores configured to use age.
+func verifyRecipientsFile(store store, file string, crypto backend.Backend, action string) {
+	signingKeys := store.Settings.SigningKeys
+	if len(signingKeys) == 0 {
+		signingKeys = strings.Fields(os.Getenv("PASSWORD_STORE_SIGNING_KEY"))
+	}
+	if len(signingKeys) == 0 {
+		return
+	}
+
+	// Whoever can change the `.gpg-id` file can change `.browserpass.json` of the store just as well,
+	// so the settings that decide about the verification, including the gpg binary and the keyring
+	// that perform it, are only trusted from the browser extension
+	message := "The settings of the password store must not configure the verification of the recipients file"
+	content, err := readDefaultSettings(store.Path)
+	var storeSettings map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal([]byte(content), &storeSettings)
+	}
+	for _, setting := range []string{"signingKeys", "backend", "gpgPath", "keyringPath"} {
+		if _, ok := storeSettings[setting]; ok && err == nil {
+			err = fmt.Errorf("The '%s' setting is not accepted from .browserpass.json while signing keys are configured", setting)
+		}
+	}
+	if err == nil && recipientsFileName(store) != helpers.GpgRecipientsFile {
+		if store.Settings.Backend == backend.NameAge {
+			return
+		}
+		err = fmt.Errorf("The store looks like an age store, which can not be verified, but the age backend is not configured")
+	}
+
+	if err == nil {
+		message = "The recipients file is not signed by any of the allowed signing keys"
+		var recipientsPath string
+		var fingerprints []string
+		recipientsPath, err = helpers.FindRecipientsFile(filepath.Join(store.Path, file), helpers.GpgRecipientsFile)
+		if err == nil {
+			fingerprints, err = crypto.VerifySignature(recipientsPath, recipientsPath+".sig")
+		}
+		if err == nil && !containsSigningKey(signingKeys, fingerprints) {
+			err = fmt.Errorf("The signature was made by the key %v, which is not one of the allowed signing keys", fingerprints)
+		}
+	}
+	if err != nil {
+		log.Errorf(
+			"Unable to verify the signature of the recipients file for the password file '%v' in the password store '%+v': %+v",
+			file, store, err,
+		)
+		response.SendErrorAndExit(
+			errors.CodeUnverifiedRecipientsFile,
+			&map[errors.Field]string{
+				errors.FieldMessage:   message,
+				errors.FieldAction:    action,
+				errors.FieldError:     err.Error(),
+				errors.FieldFile:      file,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+}
+
+// containsSigningKey checks whether any of the fingerprints is one of the allowed signing keys
+func containsSigningKey(signingKeys []string, fingerprints []string) bool {
+	for _, signingKey := range signingKeys {
+		signingKey = strings.ToUpper(strings.ReplaceAll(strings.TrimPrefix(signingKey, "0x"), " ", ""))
+		for _, fingerprint := range fingerprints {
+			if signingKey != "" && strings.EqualFold(signingKey, fingerprint) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Error codes and messages of the classified failures of the encryption backends
+var backendFailures = map[string]struct {
+	code    errors.Code
+	message string
+}{
+	backend.FailureNoSecretKey:       {errors.CodeNoSecretKey, "None of the secret keys the password file is encrypted for is available"},
+	backend.FailureBadPassphrase:     {errors.CodeBadPassphrase, "The passphrase of the secret key is incorrect"},
+	backend.FailurePinentryCancelled: {errors.CodePinentryCancelled, "The passphrase prompt was cancelled or could not be shown"},
+	backend.FailureCardNotPresent:    {errors.CodeSmartcardNotPresent, "The smartcard with the secret key is not present"},
+	backend.FailureCorruptedFile:     {errors.CodeCorruptedPasswordFile, "The password file is corrupted"},
+	backend.FailureUnusablePublicKey: {errors.CodeUnusablePublicKey, "The public key of a recipient is missing or unusable"},
+}
+
+// sendBackendFailure sends a specific error response if the error of an encryption backend
+// has a known cause, otherwise it returns and the caller sends a generic error response
+func sendBackendFailure(err error, store store, file string, action string) {
+	failure, ok := err.(*backend.Failure)
+	if !ok {
+		return
+	}
+	known, ok := backendFailures[failure.Kind]
+	if !ok {
+		return
+	}
+
+	params := map[errors.Field]string{
+		errors.FieldMessage:   known.message,
+		errors.FieldAction:    action,
+		errors.FieldError:     err.Error(),
+		errors.FieldFile:      file,
+		errors.FieldStoreID:   store.ID,
+		errors.FieldStoreName: store.Name,
+		errors.FieldStorePath: store.Path,
+	}
+	if failure.KeyIDs != nil {
+		params[errors.FieldKeyIDs] = strings.Join(failure.KeyIDs, ",")
+	}
+	response.SendErrorAndExit(known.code, &params)
+}
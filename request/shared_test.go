@@ -0,0 +1,222 @@
+package request
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/browserpass/browserpass-native/v3/backend"
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/response"
+)
+
+// recoverErrorCode runs the function in session mode and returns the code of the error response it sent, if any
+func recoverErrorCode(t *testing.T, fn func()) (code errors.Code) {
+	captureResponses(t)
+	response.StartSession()
+	defer response.EndSession()
+	defer func() {
+		if r := recover(); r != nil {
+			aborted, ok := r.(response.AbortedRequest)
+			if !ok {
+				panic(r)
+			}
+			code = aborted.Code
+		}
+	}()
+
+	fn()
+	return 0
+}
+
+// makeTestSigningKeys writes a keyring with the owner and an intruder key for the openpgp backend
+func makeTestSigningKeys(t *testing.T) (string, *openpgp.Entity, *openpgp.Entity) {
+	var keyring bytes.Buffer
+	entities := []*openpgp.Entity{}
+	for _, email := range []string{"owner@example.com", "intruder@example.com"} {
+		entity, err := openpgp.NewEntity("Test User", "", email, nil)
+		if err == nil {
+			err = entity.SerializePrivate(&keyring, nil)
+		}
+		if err != nil {
+			t.Fatalf("Unable to generate a test key: %v", err)
+		}
+		entities = append(entities, entity)
+	}
+
+	keyringPath := filepath.Join(t.TempDir(), "keyring.gpg")
+	if err := os.WriteFile(keyringPath, keyring.Bytes(), 0600); err != nil {
+		t.Fatalf("Unable to write the test keyring: %v", err)
+	}
+	return keyringPath, entities[0], entities[1]
+}
+
+func signTestFile(t *testing.T, storePath string, file string, signer *openpgp.Entity) {
+	contents, err := os.ReadFile(filepath.Join(storePath, file))
+	var signature bytes.Buffer
+	if err == nil {
+		err = openpgp.DetachSign(&signature, signer, bytes.NewReader(contents), nil)
+	}
+	if err != nil {
+		t.Fatalf("Unable to sign the test file: %v", err)
+	}
+	writeTestFile(t, storePath, file+".sig", signature.String())
+}
+
+func Test_DetectStoreBackend_FollowsSymlinksAccordingToSettings(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, "notes.txt")
+	outsidePath := makeTestStore(t, "foreign.age")
+	if err := os.Symlink(outsidePath, filepath.Join(storePath, "outside")); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+
+	// Act
+	withinStore := detectStoreBackend(store{Path: storePath})
+	always := detectStoreBackend(store{Path: storePath, Settings: StoreSettings{FollowSymlinks: followSymlinksAlways}})
+
+	// Assert
+	if withinStore != backend.NameGpg {
+		t.Fatalf("Expected the password files outside of the store to be ignored by default, but detected '%v'", withinStore)
+	}
+
+	if always != backend.NameAge {
+		t.Fatalf("Expected the password files behind the followed symlink to be detected, but detected '%v'", always)
+	}
+}
+
+func Test_ContainsSigningKey(t *testing.T) {
+	fingerprints := []string{"0123456789ABCDEF0123456789ABCDEF01234567", "89ABCDEF0123456789ABCDEF0123456789ABCDEF"}
+	tests := []struct {
+		name        string
+		signingKeys []string
+		expected    bool
+	}{
+		{"signing key listed", []string{"0123456789ABCDEF0123456789ABCDEF01234567"}, true},
+		{"primary key listed", []string{"89ABCDEF0123456789ABCDEF0123456789ABCDEF"}, true},
+		{"prefixed, spaced and lowercase", []string{"0x0123 4567 89ab cdef 0123  4567 89ab cdef 0123 4567"}, true},
+		{"unlisted signer", []string{"FEDCBA9876543210FEDCBA9876543210FEDCBA98"}, false},
+		{"empty signing key", []string{""}, false},
+	}
+
+	for _, test := range tests {
+		// Act
+		actual := containsSigningKey(test.signingKeys, fingerprints)
+
+		// Assert
+		if actual != test.expected {
+			t.Fatalf("%v: expected %v, but got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func Test_VerifyRecipientsFile(t *testing.T) {
+	keyringPath, owner, intruder := makeTestSigningKeys(t)
+	ownerFingerprint := strings.ToUpper(hex.EncodeToString(owner.PrimaryKey.Fingerprint))
+	tests := []struct {
+		name     string
+		arrange  func(storePath string)
+		backend  string
+		expected errors.Code
+	}{
+		{
+			name:    "valid signature",
+			arrange: func(storePath string) { signTestFile(t, storePath, ".gpg-id", owner) },
+		},
+		{
+			name:     "missing signature",
+			arrange:  func(storePath string) {},
+			expected: errors.CodeUnverifiedRecipientsFile,
+		},
+		{
+			name: "bad signature",
+			arrange: func(storePath string) {
+				signTestFile(t, storePath, ".gpg-id", owner)
+				writeTestFile(t, storePath, ".gpg-id", "owner@example.com\nintruder@example.com\n")
+			},
+			expected: errors.CodeUnverifiedRecipientsFile,
+		},
+		{
+			name:     "unlisted signer",
+			arrange:  func(storePath string) { signTestFile(t, storePath, ".gpg-id", intruder) },
+			expected: errors.CodeUnverifiedRecipientsFile,
+		},
+		{
+			name: "signing keys in the store settings",
+			arrange: func(storePath string) {
+				signTestFile(t, storePath, ".gpg-id", owner)
+				writeTestFile(t, storePath, ".browserpass.json", `{"signingKeys": []}`)
+			},
+			expected: errors.CodeUnverifiedRecipientsFile,
+		},
+		{
+			name: "backend in the store settings",
+			arrange: func(storePath string) {
+				signTestFile(t, storePath, ".gpg-id", owner)
+				writeTestFile(t, storePath, ".browserpass.json", `{"backend": "openpgp"}`)
+			},
+			expected: errors.CodeUnverifiedRecipientsFile,
+		},
+		{
+			name: "gpg binary in the store settings",
+			arrange: func(storePath string) {
+				signTestFile(t, storePath, ".gpg-id", owner)
+				writeTestFile(t, storePath, ".browserpass.json", `{"gpgPath": "/tmp/fake-gpg"}`)
+			},
+			expected: errors.CodeUnverifiedRecipientsFile,
+		},
+		{
+			name: "keyring in the store settings",
+			arrange: func(storePath string) {
+				signTestFile(t, storePath, ".gpg-id", owner)
+				writeTestFile(t, storePath, ".browserpass.json", `{"keyringPath": "/tmp/fake-keyring.gpg"}`)
+			},
+			expected: errors.CodeUnverifiedRecipientsFile,
+		},
+		{
+			name:     "detected age store",
+			arrange:  func(storePath string) { writeTestFile(t, storePath, ".age-recipients", "age1") },
+			backend:  "detected",
+			expected: errors.CodeUnverifiedRecipientsFile,
+		},
+		{
+			name:     "configured age store",
+			arrange:  func(storePath string) { writeTestFile(t, storePath, ".age-recipients", "age1") },
+			backend:  backend.NameAge,
+			expected: 0,
+		},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		storePath := makeTestStore(t, ".gpg-id", "site.gpg")
+		writeTestFile(t, storePath, ".gpg-id", "owner@example.com\n")
+		test.arrange(storePath)
+
+		store := store{Path: storePath, Settings: StoreSettings{
+			Backend:     backend.NameOpenPGP,
+			KeyringPath: keyringPath,
+			SigningKeys: []string{ownerFingerprint},
+		}}
+		switch test.backend {
+		case "detected":
+			store.Settings.Backend = ""
+		case backend.NameAge:
+			store.Settings.Backend = backend.NameAge
+		}
+
+		// Act
+		actual := recoverErrorCode(t, func() {
+			verifyRecipientsFile(store, "site.gpg", backend.NewOpenPGP(keyringPath), "save")
+		})
+
+		// Assert
+		if actual != test.expected {
+			t.Fatalf("%v: expected the error code %v, but got %v", test.name, test.expected, actual)
+		}
+	}
+}
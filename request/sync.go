@@ -0,0 +1,157 @@
+package request
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+func syncStores(request *request) {
+	responseData := response.MakeSyncResponse()
+
+	storeIDs := []string{}
+	if request.StoreID != "" {
+		storeIDs = append(storeIDs, request.StoreID)
+	} else {
+		for storeID := range request.Settings.Stores {
+			storeIDs = append(storeIDs, storeID)
+		}
+		sort.Strings(storeIDs)
+	}
+
+	for _, storeID := range storeIDs {
+		store := getRequestedStore(request, storeID, "sync")
+		if !isGitStore(store) {
+			// Only an explicitly requested store must be a git repository
+			if request.StoreID != "" {
+				sendSyncError(store, "The password store is not a git repository", nil)
+			}
+			continue
+		}
+
+		responseData.Stores[store.ID] = syncStore(store, request.Pull, request.Push)
+	}
+
+	response.SendOk(responseData)
+}
+
+// syncStore fetches the changes from the upstream branch, optionally integrates them with `git pull --rebase`
+// and pushes the local commits, then reports the state of the store.
+// A rebase that runs into conflicts is aborted, so that the store is never left in the middle of a rebase.
+func syncStore(store store, pull bool, push bool) *response.SyncResult {
+	result := readSyncStatus(store)
+	if result.Upstream == "" {
+		return result
+	}
+
+	if _, err := runGitRemote(store, "fetch", "--quiet"); err != nil {
+		sendSyncError(store, "Unable to fetch the changes from the remote repository", err)
+	}
+	result = readSyncStatus(store)
+
+	var pulled, pushed bool
+	if pull && result.Behind > 0 {
+		if _, err := runGitRemote(store, "pull", "--rebase", "--autostash", "--quiet"); err != nil {
+			if !isRebaseInProgress(store) {
+				sendSyncError(store, "Unable to pull the changes from the remote repository", err)
+			}
+
+			conflicts, _ := runGit(store, "-c", "core.quotePath=false", "diff", "--name-only", "--diff-filter=U")
+			if _, err := runGit(store, "rebase", "--abort"); err != nil {
+				sendSyncError(store, "Unable to abort the conflicting rebase", err)
+			}
+
+			result = readSyncStatus(store)
+			if conflicts != "" {
+				result.Conflicts = strings.Split(conflicts, "\n")
+			}
+			return result
+		}
+		result = readSyncStatus(store)
+		pulled = true
+	}
+
+	if push && result.Ahead > 0 && result.Behind == 0 {
+		if _, err := runGitRemote(store, "push", "--quiet"); err != nil {
+			sendSyncError(store, "Unable to push the changes to the remote repository", err)
+		}
+		result = readSyncStatus(store)
+		pushed = true
+	}
+
+	result.Pulled = pulled
+	result.Pushed = pushed
+	return result
+}
+
+// readSyncStatus reads the branch, the upstream branch, the number of commits ahead and behind it,
+// and whether there are uncommitted changes, all from a single `git status` call.
+// The password history is never committed, so it does not count as an uncommitted change.
+func readSyncStatus(store store) *response.SyncResult {
+	output, err := runGit(store, "status", "--porcelain=v2", "--branch", "--", ".", ":(exclude)"+historyDirectory)
+	if err != nil {
+		sendSyncError(store, "Unable to determine the status of the git repository", err)
+	}
+
+	result := response.MakeSyncResult()
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] != "#" {
+			result.Dirty = true
+			continue
+		}
+		if len(fields) < 3 {
+			continue
+		}
+
+		switch fields[1] {
+		case "branch.head":
+			result.Branch = fields[2]
+		case "branch.upstream":
+			result.Upstream = fields[2]
+		case "branch.ab":
+			result.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+			if len(fields) > 3 {
+				result.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+			}
+		}
+	}
+	return result
+}
+
+func isRebaseInProgress(store store) bool {
+	gitDir, err := runGit(store, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return false
+	}
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		if _, err := os.Stat(filepath.Join(gitDir, dir)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func sendSyncError(store store, message string, err error) {
+	log.Errorf("%v, the password store '%+v': %+v", message, store, err)
+	params := map[errors.Field]string{
+		errors.FieldMessage:   message,
+		errors.FieldAction:    "sync",
+		errors.FieldStoreID:   store.ID,
+		errors.FieldStoreName: store.Name,
+		errors.FieldStorePath: store.Path,
+	}
+	if err != nil {
+		params[errors.FieldError] = err.Error()
+	}
+	response.SendErrorAndExit(errors.CodeUnableToSyncPasswordStore, &params)
+}
@@ -2,14 +2,12 @@ package request
 
 import (
 	"os"
+	"path"
 	"path/filepath"
-	"sort"
-	"strings"
-	"sync"
 
 	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/helpers"
 	"github.com/browserpass/browserpass-native/v3/response"
-	"github.com/mattn/go-zglob/fastwalk"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -37,32 +35,9 @@ func listDirectories(request *request) {
 		}
 
 		store.Path = normalizedStorePath
+		store.backendName = getStoreBackendName(store)
 
-		var mu sync.Mutex
-		directories := []string{}
-		err = fastwalk.FastWalk(store.Path, func(path string, typ os.FileMode) error {
-			if typ == os.ModeSymlink {
-				followedPath, err := filepath.EvalSymlinks(path)
-				if err == nil {
-					fi, err := os.Lstat(followedPath)
-					if err == nil && fi.IsDir() {
-						return fastwalk.TraverseLink
-					}
-				}
-			}
-
-			if typ.IsDir() && path != store.Path {
-				if filepath.Base(path) == ".git" {
-					return filepath.SkipDir
-				}
-				mu.Lock()
-				directories = append(directories, path)
-				mu.Unlock()
-			}
-
-			return nil
-		})
-
+		index, err := indexStore(store)
 		if err != nil {
 			log.Errorf(
 				"Unable to list the directory tree in the password store '%+v' at its location: %+v",
@@ -81,32 +56,110 @@ func listDirectories(request *request) {
 			)
 		}
 
-		for i, directory := range directories {
-			relativePath, err := filepath.Rel(store.Path, directory)
-			if err != nil {
-				log.Errorf(
-					"Unable to determine the relative path for a file '%v' in the password store '%+v': %+v",
-					directory, store, err,
-				)
-				response.SendErrorAndExit(
-					errors.CodeUnableToDetermineRelativeDirectoryPathInPasswordStore,
-					&map[errors.Field]string{
-						errors.FieldMessage:   "Unable to determine the relative path for a directory in the password store",
-						errors.FieldAction:    "tree",
-						errors.FieldError:     err.Error(),
-						errors.FieldDirectory: directory,
-						errors.FieldStoreID:   store.ID,
-						errors.FieldStoreName: store.Name,
-						errors.FieldStorePath: store.Path,
-					},
-				)
-			}
-			directories[i] = strings.Replace(relativePath, "\\", "/", -1) // normalize Windows paths
+		responseData.Directories[store.ID] = index.Directories
+		responseData.Warnings[store.ID] = index.Warnings
+
+		if !request.Nested {
+			continue
 		}
 
-		sort.Strings(directories)
-		responseData.Directories[store.ID] = directories
+		if responseData.Nested == nil {
+			responseData.Nested = make(map[string]*response.TreeNode)
+		}
+		responseData.Nested[store.ID], err = buildStoreTree(store, index)
+		if err != nil {
+			log.Errorf(
+				"Unable to build the nested directory tree of the password store '%+v': %+v",
+				store, err,
+			)
+			response.SendErrorAndExit(
+				errors.CodeUnableToListDirectoriesInPasswordStore,
+				&map[errors.Field]string{
+					errors.FieldMessage:   "Unable to build the nested directory tree of the password store",
+					errors.FieldAction:    "tree",
+					errors.FieldError:     err.Error(),
+					errors.FieldStoreID:   store.ID,
+					errors.FieldStoreName: store.Name,
+					errors.FieldStorePath: store.Path,
+				},
+			)
+		}
 	}
 
 	response.SendOk(responseData)
 }
+
+// buildStoreTree turns the flat store index into a tree of directories with per-directory statistics,
+// the root node represents the store itself
+func buildStoreTree(store store, index *storeIndex) (*response.TreeNode, error) {
+	root, err := makeTreeNode(store, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The store root may inherit recipients from its parent directories, exactly as `save` does
+	if !root.HasGpgID {
+		if recipients, err := helpers.DetectRecipients(filepath.Join(store.Path, recipientsFileName(store)), recipientsFileName(store)); err == nil {
+			root.Recipients = recipients
+		}
+	}
+
+	// Parents always come before their children in the sorted list of directories
+	nodes := map[string]*response.TreeNode{"": root}
+	for _, directory := range index.Directories {
+		parent := nodes[parentDirectory(directory)]
+		node, err := makeTreeNode(store, directory, parent.Recipients)
+		if err != nil {
+			return nil, err
+		}
+		parent.Children = append(parent.Children, node)
+		nodes[directory] = node
+	}
+
+	for _, file := range index.Files {
+		nodes[parentDirectory(file)].Entries++
+	}
+
+	return root, nil
+}
+
+func makeTreeNode(store store, directory string, inheritedRecipients []string) (*response.TreeNode, error) {
+	absolutePath := filepath.Join(store.Path, filepath.FromSlash(directory))
+	node := &response.TreeNode{
+		Name:       path.Base(directory),
+		Path:       directory,
+		Recipients: inheritedRecipients,
+		Children:   []*response.TreeNode{},
+	}
+	if directory == "" {
+		node.Name = store.Name
+	}
+
+	recipients, err := helpers.ReadRecipients(absolutePath, recipientsFileName(store))
+	if err == nil {
+		node.HasGpgID = true
+		node.Recipients = recipients
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if node.Recipients == nil {
+		node.Recipients = []string{}
+	}
+
+	_, err = os.Stat(filepath.Join(absolutePath, ".browserpass.json"))
+	if err == nil {
+		node.HasSettings = true
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+func parentDirectory(relativePath string) string {
+	parent := path.Dir(relativePath)
+	if parent == "." {
+		return ""
+	}
+	return parent
+}
@@ -0,0 +1,67 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/browserpass/browserpass-native/v3/response"
+)
+
+func Test_BuildStoreTree_InheritsRecipients(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t,
+		".gpg-id",
+		"personal.gpg",
+		"team/.browserpass.json",
+		"team/shared.gpg",
+		"team/ops/server.gpg",
+		"team/ops/db.gpg",
+	)
+	writeTestFile(t, storePath, ".gpg-id", "me@example.com\n")
+	writeTestFile(t, storePath, "team/ops/.gpg-id", "me@example.com\r\nops@example.com\n")
+	store := store{Name: "default", Path: storePath}
+
+	expected := &response.TreeNode{
+		Name:       "default",
+		Path:       "",
+		Entries:    1,
+		HasGpgID:   true,
+		Recipients: []string{"me@example.com"},
+		Children: []*response.TreeNode{
+			{
+				Name:        "team",
+				Path:        "team",
+				Entries:     1,
+				Recipients:  []string{"me@example.com"},
+				HasSettings: true,
+				Children: []*response.TreeNode{
+					{
+						Name:       "ops",
+						Path:       "team/ops",
+						Entries:    2,
+						HasGpgID:   true,
+						Recipients: []string{"me@example.com", "ops@example.com"},
+						Children:   []*response.TreeNode{},
+					},
+				},
+			},
+		},
+	}
+
+	index, err := indexStore(store)
+	if err != nil {
+		t.Fatalf("Error indexing the store: %v", err)
+	}
+
+	// Act
+	actual, err := buildStoreTree(store, index)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error building the store tree: %v", err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("The store tree was built incorrectly.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
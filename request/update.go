@@ -0,0 +1,67 @@
+package request
+
+import (
+	"fmt"
+
+	"github.com/browserpass/browserpass-native/v3/entry"
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/helpers"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+func updateEntry(request *request) {
+	responseData := response.MakeUpdateResponse()
+
+	if len(request.Changes) == 0 {
+		log.Errorf("The entry changes are missing")
+		response.SendErrorAndExit(
+			errors.CodeInvalidEntryChanges,
+			&map[errors.Field]string{
+				errors.FieldMessage: "The entry changes are missing",
+				errors.FieldAction:  "update",
+			},
+		)
+	}
+
+	store := getRequestedStore(request, request.StoreID, "update")
+	requirePasswordFileExtension(store, request.File, "update")
+	crypto := getBackend(request, store, "update")
+
+	requireFileVersion(store, request.File, request.IfMatch, false, "update")
+
+	decrypted, _ := decryptPasswordFile(store, request.File, crypto, "update")
+	updated, err := entry.Update(string(decrypted), request.Changes, store.Settings.FieldAliases)
+	helpers.WipeBytes(decrypted)
+	if err != nil {
+		log.Errorf(
+			"Unable to apply the changes to the password file '%v' in the password store '%+v': %+v",
+			request.File, store, err,
+		)
+		response.SendErrorAndExit(
+			errors.CodeInvalidEntryChanges,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "Unable to apply the changes to the password file",
+				errors.FieldAction:    "update",
+				errors.FieldError:     err.Error(),
+				errors.FieldFile:      request.File,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+
+	contents := []byte(updated)
+	archivePasswordFile(store, request.File, "update")
+	encryptPasswordFile(store, request.File, contents, crypto, "update")
+	helpers.WipeBytes(contents)
+
+	responseData.Version = getFileVersion(store, request.File, "update")
+	responseData.Commit = commitPasswordFiles(
+		store, []string{request.File},
+		fmt.Sprintf("Edit password for %s using browserpass.", entryName(store, request.File)), "update",
+	)
+
+	response.SendOk(responseData)
+}
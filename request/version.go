@@ -0,0 +1,93 @@
+package request
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/response"
+	log "github.com/sirupsen/logrus"
+)
+
+// fileVersion identifies the current contents of a password file by a hash of the ciphertext
+// and the modification time, an empty version means that the file does not exist
+func fileVersion(filePath string) (string, error) {
+	fi, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(ciphertext)
+	return fmt.Sprintf("%x-%x", hash[:16], fi.ModTime().UnixNano()), nil
+}
+
+// getFileVersion returns the current version of a password file, sending an error response on failure
+func getFileVersion(store store, file string, action string) string {
+	version, err := fileVersion(filepath.Join(store.Path, file))
+	if err != nil {
+		log.Errorf(
+			"Unable to read the password file '%v' in the password store '%+v': %+v",
+			file, store, err,
+		)
+		response.SendErrorAndExit(
+			errors.CodeUnableToDecryptPasswordFile,
+			&map[errors.Field]string{
+				errors.FieldMessage:   "Unable to read the password file",
+				errors.FieldAction:    action,
+				errors.FieldError:     err.Error(),
+				errors.FieldFile:      file,
+				errors.FieldStoreID:   store.ID,
+				errors.FieldStoreName: store.Name,
+				errors.FieldStorePath: store.Path,
+			},
+		)
+	}
+	return version
+}
+
+// requireFileVersion sends a conflict error response if the password file has changed since
+// the browser extension has read it (ifMatch), or if it already exists when it should be created (ifNoneMatch)
+func requireFileVersion(store store, file string, ifMatch string, ifNoneMatch bool, action string) {
+	if ifMatch == "" && !ifNoneMatch {
+		return
+	}
+
+	version := getFileVersion(store, file, action)
+
+	var message string
+	switch {
+	case ifNoneMatch && version != "":
+		message = "The password file already exists"
+	case ifMatch != "" && version != ifMatch:
+		message = "The password file has changed since it was read"
+	default:
+		return
+	}
+
+	log.Errorf(
+		"%v: '%v' in the password store '%+v', expected version '%v', current version '%v'",
+		message, file, store, ifMatch, version,
+	)
+	response.SendErrorAndExit(
+		errors.CodePasswordFileConflict,
+		&map[errors.Field]string{
+			errors.FieldMessage:   message,
+			errors.FieldAction:    action,
+			errors.FieldFile:      file,
+			errors.FieldVersion:   version,
+			errors.FieldStoreID:   store.ID,
+			errors.FieldStoreName: store.Name,
+			errors.FieldStorePath: store.Path,
+		},
+	)
+}
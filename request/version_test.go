@@ -0,0 +1,71 @@
+package request
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_FileVersion_ChangesWithContents(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, "site.gpg")
+	filePath := filepath.Join(storePath, "site.gpg")
+	before, err := fileVersion(filePath)
+	if err != nil {
+		t.Fatalf("Error getting the version: %v", err)
+	}
+
+	// Act
+	writeTestFile(t, storePath, "site.gpg", "modified")
+	mtime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filePath, mtime, mtime); err != nil {
+		t.Fatalf("Unable to change the modification time: %v", err)
+	}
+	after, err := fileVersion(filePath)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error getting the version: %v", err)
+	}
+
+	if before == "" || after == "" || before == after {
+		t.Fatalf("Expected different versions, but got '%v' and '%v'", before, after)
+	}
+}
+
+func Test_FileVersion_StableWithoutChanges(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, "site.gpg")
+	filePath := filepath.Join(storePath, "site.gpg")
+
+	// Act
+	first, err1 := fileVersion(filePath)
+	second, err2 := fileVersion(filePath)
+
+	// Assert
+	if err1 != nil || err2 != nil {
+		t.Fatalf("Error getting the version: %v, %v", err1, err2)
+	}
+
+	if first != second {
+		t.Fatalf("Expected the same version, but got '%v' and '%v'", first, second)
+	}
+}
+
+func Test_FileVersion_MissingFile(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t)
+
+	// Act
+	version, err := fileVersion(filepath.Join(storePath, "missing.gpg"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error getting the version: %v", err)
+	}
+
+	if version != "" {
+		t.Fatalf("Expected an empty version for a missing file, but got '%v'", version)
+	}
+}
@@ -0,0 +1,241 @@
+package request
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/browserpass/browserpass-native/v3/response"
+)
+
+// Possible values of the followSymlinks store setting
+const (
+	followSymlinksNever       = "never"
+	followSymlinksWithinStore = "within-store"
+	followSymlinksAlways      = "always"
+)
+
+// storeVisitor is called for every password file and directory found in a password store,
+// relativePath always uses forward slashes as separators
+type storeVisitor func(relativePath string, isDir bool) error
+
+type storeIndex struct {
+	Files       []string
+	Directories []string
+	Warnings    []response.WalkWarning
+}
+
+// storeWalker traverses password stores, every list of files or directories produced
+// by the host app must go through it, so that the traversal rules can never diverge between actions.
+//
+// `.git` directories and the history directory in the store root are skipped, and only password files
+// (`*.gpg`, or `*.age` in passage-compatible stores) are reported. Symlinks are handled according to
+// the followSymlinks store setting, and directories that are already being traversed
+// (identified by device and inode) are never entered again, which breaks symlink loops.
+type storeWalker struct {
+	storePath      string
+	followSymlinks string
+	extension      string
+	ancestors      []os.FileInfo
+	warnings       []response.WalkWarning
+}
+
+func newStoreWalker(store store) (*storeWalker, error) {
+	return newFileWalker(store, passwordFileExtension(store))
+}
+
+// newFileWalker creates a walker that reports the files with the given extension instead of the password files,
+// or all files if the extension is empty
+func newFileWalker(store store, extension string) (*storeWalker, error) {
+	followSymlinks := store.Settings.FollowSymlinks
+	switch followSymlinks {
+	case "":
+		// Following symlinks out of the store must be requested explicitly, a symlink to `/` or to the home
+		// directory would otherwise make every list, tree and index walk the whole file system
+		followSymlinks = followSymlinksWithinStore
+	case followSymlinksNever, followSymlinksWithinStore, followSymlinksAlways:
+	default:
+		return nil, errors.New("Invalid value of the followSymlinks setting: " + followSymlinks)
+	}
+
+	return &storeWalker{
+		storePath:      store.Path,
+		followSymlinks: followSymlinks,
+		extension:      extension,
+		warnings:       []response.WalkWarning{},
+	}, nil
+}
+
+// walk traverses the whole password store in a single pass
+func (w *storeWalker) walk(visit storeVisitor) error {
+	return w.walkDirectory("", visit)
+}
+
+// walkDirectory traverses a directory of a password store in a single pass
+func (w *storeWalker) walkDirectory(relativeDir string, visit storeVisitor) error {
+	// Remember every directory on the way from the store root, to detect loops below relativeDir
+	w.ancestors = nil
+	current := relativeDir
+	for {
+		fi, err := os.Stat(w.absolutePath(current))
+		if err != nil {
+			return err
+		}
+		w.ancestors = append(w.ancestors, fi)
+
+		if current == "" {
+			break
+		}
+		if current = path.Dir(current); current == "." {
+			current = ""
+		}
+	}
+
+	return w.walkChildren(relativeDir, visit)
+}
+
+func (w *storeWalker) walkChildren(relativeDir string, visit storeVisitor) error {
+	entries, err := os.ReadDir(w.absolutePath(relativeDir))
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		relativePath := path.Join(relativeDir, name)
+
+		var fi os.FileInfo
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			var follow bool
+			if fi, follow = w.resolveSymlink(relativePath); !follow {
+				continue
+			}
+			isDir = fi.IsDir()
+		}
+
+		if isDir {
+			if name == ".git" || (relativeDir == "" && name == historyDirectory) {
+				continue
+			}
+
+			if fi == nil {
+				if fi, err = entry.Info(); err != nil {
+					return err
+				}
+			}
+			if w.isAncestor(fi) {
+				w.warn(relativePath, response.WalkWarningSymlinkLoop)
+				continue
+			}
+
+			if err := visit(relativePath, true); err != nil {
+				return err
+			}
+
+			w.ancestors = append(w.ancestors, fi)
+			err = w.walkChildren(relativePath, visit)
+			w.ancestors = w.ancestors[:len(w.ancestors)-1]
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if strings.HasSuffix(name, w.extension) {
+			if err := visit(relativePath, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveSymlink decides whether a symlink should be followed according to the store settings
+func (w *storeWalker) resolveSymlink(relativePath string) (os.FileInfo, bool) {
+	if w.followSymlinks == followSymlinksNever {
+		return nil, false
+	}
+
+	absolutePath := w.absolutePath(relativePath)
+	target, err := filepath.EvalSymlinks(absolutePath)
+	if err != nil {
+		// Dangling symlink or a loop of symlinks pointing at each other, nothing to report
+		return nil, false
+	}
+
+	fi, err := os.Stat(target)
+	if err != nil {
+		return nil, false
+	}
+
+	if target != w.storePath && !strings.HasPrefix(target, w.storePath+string(filepath.Separator)) {
+		w.warnTarget(relativePath, target, response.WalkWarningOutsideStore)
+		if w.followSymlinks == followSymlinksWithinStore {
+			return nil, false
+		}
+	}
+
+	return fi, true
+}
+
+func (w *storeWalker) isAncestor(fi os.FileInfo) bool {
+	for _, ancestor := range w.ancestors {
+		// os.SameFile compares device and inode numbers (or their equivalents on Windows)
+		if os.SameFile(ancestor, fi) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *storeWalker) warn(relativePath string, reason string) {
+	target, _ := os.Readlink(w.absolutePath(relativePath))
+	w.warnTarget(relativePath, target, reason)
+}
+
+func (w *storeWalker) warnTarget(relativePath string, target string, reason string) {
+	w.warnings = append(w.warnings, response.WalkWarning{
+		Path:   relativePath,
+		Target: target,
+		Reason: reason,
+	})
+}
+
+func (w *storeWalker) absolutePath(relativePath string) string {
+	return filepath.Join(w.storePath, filepath.FromSlash(relativePath))
+}
+
+// indexStore collects sorted lists of all password files and directories in a password store
+func indexStore(store store) (*storeIndex, error) {
+	walker, err := newStoreWalker(store)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &storeIndex{
+		Files:       []string{},
+		Directories: []string{},
+	}
+
+	err = walker.walk(func(relativePath string, isDir bool) error {
+		if isDir {
+			index.Directories = append(index.Directories, relativePath)
+		} else {
+			index.Files = append(index.Files, relativePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(index.Files)
+	sort.Strings(index.Directories)
+	index.Warnings = walker.warnings
+	return index, nil
+}
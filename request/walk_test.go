@@ -0,0 +1,173 @@
+package request
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/browserpass/browserpass-native/v3/response"
+)
+
+func makeTestStore(t *testing.T, files ...string) string {
+	storePath := t.TempDir()
+	for _, file := range files {
+		filePath := filepath.Join(storePath, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatalf("Unable to create a directory for the test store: %v", err)
+		}
+		if err := os.WriteFile(filePath, []byte(file), 0644); err != nil {
+			t.Fatalf("Unable to create a file for the test store: %v", err)
+		}
+	}
+	return storePath
+}
+
+func writeTestFile(t *testing.T, storePath string, file string, contents string) {
+	if err := os.WriteFile(filepath.Join(storePath, filepath.FromSlash(file)), []byte(contents), 0644); err != nil {
+		t.Fatalf("Unable to write a file in the test store: %v", err)
+	}
+}
+
+func Test_IndexStore_FilesAndDirectoriesInOnePass(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t,
+		"root.gpg",
+		"notes.txt",
+		".gpg-id",
+		"work/email.gpg",
+		"work/servers/db.gpg",
+		"empty/.keep",
+		".git/objects/fake.gpg",
+	)
+	expected := &storeIndex{
+		Files:       []string{"root.gpg", "work/email.gpg", "work/servers/db.gpg"},
+		Directories: []string{"empty", "work", "work/servers"},
+		Warnings:    []response.WalkWarning{},
+	}
+
+	// Act
+	actual, err := indexStore(store{Path: storePath})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error indexing the store: %v", err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("The store was indexed incorrectly.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
+
+func Test_IndexStore_FollowsSymlinks(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, "shared/team.gpg")
+	if err := os.Symlink(filepath.Join(storePath, "shared"), filepath.Join(storePath, "linked")); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+	expected := &storeIndex{
+		Files:       []string{"linked/team.gpg", "shared/team.gpg"},
+		Directories: []string{"linked", "shared"},
+		Warnings:    []response.WalkWarning{},
+	}
+
+	// Act
+	actual, err := indexStore(store{Path: storePath})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error indexing the store: %v", err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("The store was indexed incorrectly.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
+
+func Test_IndexStore_BreaksSymlinkLoops(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, "a/entry.gpg")
+	if err := os.Symlink(storePath, filepath.Join(storePath, "a", "loop")); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+	expected := &storeIndex{
+		Files:       []string{"a/entry.gpg"},
+		Directories: []string{"a"},
+		Warnings: []response.WalkWarning{
+			{Path: "a/loop", Target: storePath, Reason: response.WalkWarningSymlinkLoop},
+		},
+	}
+
+	// Act
+	actual, err := indexStore(store{Path: storePath})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error indexing the store: %v", err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("The store was indexed incorrectly.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
+
+func Test_IndexStore_SkipsSymlinksOutsideStoreByDefault(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, "own.gpg")
+	outsidePath := makeTestStore(t, "foreign.gpg")
+	if err := os.Symlink(outsidePath, filepath.Join(storePath, "outside")); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+	outsidePath, _ = filepath.EvalSymlinks(outsidePath)
+	expected := &storeIndex{
+		Files:       []string{"own.gpg"},
+		Directories: []string{},
+		Warnings: []response.WalkWarning{
+			{Path: "outside", Target: outsidePath, Reason: response.WalkWarningOutsideStore},
+		},
+	}
+
+	// Act
+	actual, err := indexStore(store{Path: storePath})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error indexing the store: %v", err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("The store was indexed incorrectly.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
+
+func Test_IndexStore_FollowsSymlinksOutsideStoreIfRequested(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, "own.gpg")
+	outsidePath := makeTestStore(t, "foreign.gpg")
+	if err := os.Symlink(outsidePath, filepath.Join(storePath, "outside")); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+	outsidePath, _ = filepath.EvalSymlinks(outsidePath)
+	expected := &storeIndex{
+		Files:       []string{"outside/foreign.gpg", "own.gpg"},
+		Directories: []string{"outside"},
+		Warnings: []response.WalkWarning{
+			{Path: "outside", Target: outsidePath, Reason: response.WalkWarningOutsideStore},
+		},
+	}
+
+	// Act
+	actual, err := indexStore(store{
+		Path:     storePath,
+		Settings: StoreSettings{FollowSymlinks: followSymlinksAlways},
+	})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Error indexing the store: %v", err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("The store was indexed incorrectly.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
@@ -0,0 +1,282 @@
+package request
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/response"
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// How long to wait for the file system to settle before reporting changes,
+// so that bulk operations like `git pull` are reported as a single event
+const watchDebounceDelay = 250 * time.Millisecond
+
+type watchedStore struct {
+	store   store
+	walker  *storeWalker
+	entries map[string]bool
+	pending map[string]bool
+}
+
+type storeWatcher struct {
+	watcher *fsnotify.Watcher
+	stores  []*watchedStore
+	mu      sync.Mutex
+	timer   *time.Timer
+}
+
+var activeWatcher *storeWatcher
+
+func watchStores(request *request) {
+	responseData := response.MakeWatchResponse()
+
+	stores := []store{}
+	for _, store := range request.Settings.Stores {
+		normalizedStorePath, err := normalizePasswordStorePath(store.Path)
+		if err != nil {
+			log.Errorf(
+				"The password store '%+v' is not accessible at its location: %+v",
+				store, err,
+			)
+			response.SendErrorAndExit(
+				errors.CodeInaccessiblePasswordStore,
+				&map[errors.Field]string{
+					errors.FieldMessage:   "The password store is not accessible",
+					errors.FieldAction:    "watch",
+					errors.FieldError:     err.Error(),
+					errors.FieldStoreID:   store.ID,
+					errors.FieldStoreName: store.Name,
+					errors.FieldStorePath: store.Path,
+				},
+			)
+		}
+		store.Path = normalizedStorePath
+		store.backendName = getStoreBackendName(store)
+		stores = append(stores, store)
+	}
+
+	if activeWatcher != nil {
+		activeWatcher.close()
+		activeWatcher = nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Error("Unable to initialize the file system watcher: ", err)
+		response.SendErrorAndExit(
+			errors.CodeUnableToWatchPasswordStore,
+			&map[errors.Field]string{
+				errors.FieldMessage: "Unable to initialize the file system watcher",
+				errors.FieldAction:  "watch",
+				errors.FieldError:   err.Error(),
+			},
+		)
+	}
+
+	sw := &storeWatcher{watcher: watcher}
+	for _, store := range stores {
+		walker, err := newStoreWalker(store)
+		if err == nil {
+			ws := &watchedStore{
+				store:   store,
+				walker:  walker,
+				entries: make(map[string]bool),
+				pending: make(map[string]bool),
+			}
+			sw.stores = append(sw.stores, ws)
+			err = sw.addDirectory(ws, "", false)
+		}
+		if err != nil {
+			watcher.Close()
+			log.Errorf(
+				"Unable to watch the password store '%+v' for changes: %+v",
+				store, err,
+			)
+			response.SendErrorAndExit(
+				errors.CodeUnableToWatchPasswordStore,
+				&map[errors.Field]string{
+					errors.FieldMessage:   "Unable to watch the password store for changes",
+					errors.FieldAction:    "watch",
+					errors.FieldError:     err.Error(),
+					errors.FieldStoreID:   store.ID,
+					errors.FieldStoreName: store.Name,
+					errors.FieldStorePath: store.Path,
+				},
+			)
+		}
+	}
+
+	activeWatcher = sw
+	go sw.run()
+
+	response.StartSession()
+	response.SendOk(responseData)
+}
+
+// addDirectory recursively subscribes to changes in a directory and records the entries in it.
+// If markPending is set, the discovered entries are also queued for classification,
+// which is needed for directories that appear after the watch has started.
+func (sw *storeWatcher) addDirectory(ws *watchedStore, relativeDir string, markPending bool) error {
+	if err := sw.watcher.Add(filepath.Join(ws.store.Path, filepath.FromSlash(relativeDir))); err != nil {
+		return err
+	}
+
+	ws.walker.warnings = nil
+	defer func() {
+		for _, warning := range ws.walker.warnings {
+			log.Warnf("Encountered a problematic symlink while watching the password store '%+v': %+v", ws.store, warning)
+		}
+	}()
+
+	return ws.walker.walkDirectory(relativeDir, func(relativePath string, isDir bool) error {
+		if isDir {
+			return sw.watcher.Add(filepath.Join(ws.store.Path, filepath.FromSlash(relativePath)))
+		}
+
+		if markPending {
+			ws.pending[relativePath] = true
+		} else {
+			ws.entries[relativePath] = true
+		}
+		return nil
+	})
+}
+
+func (sw *storeWatcher) run() {
+	for {
+		select {
+		case event, ok := <-sw.watcher.Events:
+			if !ok {
+				return
+			}
+			sw.handleEvent(event)
+		case err, ok := <-sw.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warn("Received an error from the file system watcher: ", err)
+		}
+	}
+}
+
+func (sw *storeWatcher) close() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.timer != nil {
+		sw.timer.Stop()
+	}
+	sw.watcher.Close()
+}
+
+func (sw *storeWatcher) handleEvent(event fsnotify.Event) {
+	ws := sw.findStore(event.Name)
+	if ws == nil {
+		return
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	relativePath := ws.relativePath(event.Name)
+	if relativePath == ".git" || strings.HasPrefix(relativePath, ".git/") ||
+		relativePath == historyDirectory || strings.HasPrefix(relativePath, historyDirectory+"/") {
+		return
+	}
+
+	if event.Has(fsnotify.Create) {
+		if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+			if err := sw.addDirectory(ws, relativePath, true); err != nil {
+				log.Warnf("Unable to watch the new directory '%v' for changes: %+v", event.Name, err)
+			}
+		}
+	}
+
+	if strings.HasSuffix(relativePath, ws.walker.extension) {
+		ws.pending[relativePath] = true
+	} else if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		// A removed or renamed directory takes all known entries inside it along
+		for entry := range ws.entries {
+			if strings.HasPrefix(entry, relativePath+"/") {
+				ws.pending[entry] = true
+			}
+		}
+	}
+
+	if len(ws.pending) == 0 {
+		return
+	}
+
+	if sw.timer == nil {
+		sw.timer = time.AfterFunc(watchDebounceDelay, sw.flush)
+	} else {
+		sw.timer.Reset(watchDebounceDelay)
+	}
+}
+
+// flush classifies all pending entries and sends a storeChanged event for every affected store
+func (sw *storeWatcher) flush() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	for _, ws := range sw.stores {
+		if len(ws.pending) == 0 {
+			continue
+		}
+
+		event := response.MakeStoreChangedEvent(ws.store.ID)
+		for entry := range ws.pending {
+			fi, err := os.Stat(filepath.Join(ws.store.Path, entry))
+			exists := err == nil && !fi.IsDir()
+			switch {
+			case exists && ws.entries[entry]:
+				event.Modified = append(event.Modified, entry)
+			case exists:
+				ws.entries[entry] = true
+				event.Added = append(event.Added, entry)
+			case ws.entries[entry]:
+				delete(ws.entries, entry)
+				event.Removed = append(event.Removed, entry)
+			}
+		}
+		ws.pending = make(map[string]bool)
+
+		if len(event.Added)+len(event.Removed)+len(event.Modified) == 0 {
+			continue
+		}
+
+		sort.Strings(event.Added)
+		sort.Strings(event.Removed)
+		sort.Strings(event.Modified)
+		response.SendEvent("storeChanged", event)
+	}
+}
+
+func (sw *storeWatcher) findStore(path string) *watchedStore {
+	var found *watchedStore
+	for _, ws := range sw.stores {
+		if path != ws.store.Path && !strings.HasPrefix(path, ws.store.Path+string(filepath.Separator)) {
+			continue
+		}
+		// Prefer the most specific store if one store is nested in another
+		if found == nil || len(ws.store.Path) > len(found.store.Path) {
+			found = ws
+		}
+	}
+	return found
+}
+
+func (ws *watchedStore) relativePath(path string) string {
+	relativePath, err := filepath.Rel(ws.store.Path, path)
+	if err != nil {
+		return path
+	}
+	return strings.Replace(relativePath, "\\", "/", -1) // normalize Windows paths
+}
@@ -0,0 +1,59 @@
+package request
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/browserpass/browserpass-native/v3/response"
+)
+
+func Test_WatchStores_IgnoresGitAndHistory(t *testing.T) {
+	// Arrange
+	storePath := makeTestStore(t, "site.gpg")
+	responses := captureResponses(t)
+
+	watchStores(&request{
+		Action:   "watch",
+		Settings: settings{Stores: map[string]store{"test": {ID: "test", Path: storePath}}},
+	})
+	t.Cleanup(func() {
+		activeWatcher.close()
+		activeWatcher = nil
+		response.EndSession()
+	})
+
+	if started := receiveResponse(t, responses); started["status"] != "ok" {
+		t.Fatalf("Unable to start watching the store: %+v", started)
+	}
+
+	// Act
+	for _, dir := range []string{".git/refs", ".history/site"} {
+		if err := os.MkdirAll(filepath.Join(storePath, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatalf("Unable to create a directory in the test store: %v", err)
+		}
+	}
+	writeTestFile(t, storePath, ".git/refs/site.gpg", "git")
+	writeTestFile(t, storePath, ".history/site/20240102T000000.000000000Z.gpg", "history")
+	writeTestFile(t, storePath, "site.gpg", "modified")
+
+	// Assert
+	event := receiveResponse(t, responses)
+	expected := map[string]interface{}{
+		"storeId":  "test",
+		"added":    []interface{}{},
+		"removed":  []interface{}{},
+		"modified": []interface{}{"site.gpg"},
+	}
+	if event["event"] != "storeChanged" || !reflect.DeepEqual(event["data"], expected) {
+		t.Fatalf("Expected only the password file to be reported as modified, but got %+v", event)
+	}
+
+	select {
+	case unexpected := <-responses:
+		t.Fatalf("Expected no further events, but got %+v", unexpected)
+	case <-time.After(4 * watchDebounceDelay):
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"os"
+	"sync"
 
+	"github.com/browserpass/browserpass-native/v3/backend"
+	"github.com/browserpass/browserpass-native/v3/entry"
 	"github.com/browserpass/browserpass-native/v3/errors"
+	"github.com/browserpass/browserpass-native/v3/generate"
 	"github.com/browserpass/browserpass-native/v3/version"
 	log "github.com/sirupsen/logrus"
 )
@@ -17,6 +21,13 @@ type okResponse struct {
 	Data    interface{} `json:"data"`
 }
 
+type eventResponse struct {
+	Status  string      `json:"status"`
+	Event   string      `json:"event"`
+	Version int         `json:"version"`
+	Data    interface{} `json:"data"`
+}
+
 type errorResponse struct {
 	Status  string      `json:"status"`
 	Code    errors.Code `json:"code"`
@@ -29,44 +40,98 @@ type ConfigureResponse struct {
 	DefaultStore struct {
 		Path     string `json:"path"`
 		Settings string `json:"settings"`
+		Backend  string `json:"backend"`
 	} `json:"defaultStore"`
 	StoreSettings map[string]string `json:"storeSettings"`
+	StoreBackends map[string]string `json:"storeBackends"`
 }
 
 // MakeConfigureResponse initializes an empty configure response
 func MakeConfigureResponse() *ConfigureResponse {
 	return &ConfigureResponse{
 		StoreSettings: make(map[string]string),
+		StoreBackends: make(map[string]string),
 	}
 }
 
+// Reasons of the warnings produced while traversing a password store
+const (
+	WalkWarningSymlinkLoop  = "symlinkLoop"
+	WalkWarningOutsideStore = "outsideStore"
+)
+
+// WalkWarning a warning about a symlink encountered while traversing a password store
+type WalkWarning struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+}
+
 // ListResponse a response format for the "list" request
 type ListResponse struct {
-	Files map[string][]string `json:"files"`
+	Files    map[string][]string      `json:"files"`
+	Total    map[string]int           `json:"total"`
+	Warnings map[string][]WalkWarning `json:"warnings"`
 }
 
 // MakeListResponse initializes an empty list response
 func MakeListResponse() *ListResponse {
 	return &ListResponse{
-		Files: make(map[string][]string),
+		Files:    make(map[string][]string),
+		Total:    make(map[string]int),
+		Warnings: make(map[string][]WalkWarning),
 	}
 }
 
+// TreeNode a directory in the nested representation of a password store
+type TreeNode struct {
+	Name        string      `json:"name"`
+	Path        string      `json:"path"`
+	Entries     int         `json:"entries"`
+	HasGpgID    bool        `json:"hasGpgId"`
+	Recipients  []string    `json:"recipients"`
+	HasSettings bool        `json:"hasSettings"`
+	Children    []*TreeNode `json:"children"`
+}
+
 // TreeResponse a response format for the "tree" request
 type TreeResponse struct {
-	Directories map[string][]string `json:"directories"`
+	Directories map[string][]string      `json:"directories"`
+	Nested      map[string]*TreeNode     `json:"nested,omitempty"`
+	Warnings    map[string][]WalkWarning `json:"warnings"`
 }
 
 // MakeTreeResponse initializes an empty tree response
 func MakeTreeResponse() *TreeResponse {
 	return &TreeResponse{
 		Directories: make(map[string][]string),
+		Warnings:    make(map[string][]WalkWarning),
+	}
+}
+
+// IndexResponse a response format for the "index" request
+type IndexResponse struct {
+	Files       map[string][]string      `json:"files"`
+	Directories map[string][]string      `json:"directories"`
+	Warnings    map[string][]WalkWarning `json:"warnings"`
+}
+
+// MakeIndexResponse initializes an empty index response
+func MakeIndexResponse() *IndexResponse {
+	return &IndexResponse{
+		Files:       make(map[string][]string),
+		Directories: make(map[string][]string),
+		Warnings:    make(map[string][]WalkWarning),
 	}
 }
 
 // FetchResponse a response format for the "fetch" request
 type FetchResponse struct {
-	Contents string `json:"contents"`
+	Contents string            `json:"contents"`
+	Encoding string            `json:"encoding,omitempty"`
+	Version  string            `json:"version"`
+	Parsed   *entry.Entry      `json:"parsed,omitempty"`
+	Meta     *backend.Metadata `json:"meta,omitempty"`
 }
 
 // MakeFetchResponse initializes an empty fetch response
@@ -74,8 +139,68 @@ func MakeFetchResponse() *FetchResponse {
 	return &FetchResponse{}
 }
 
+// FetchFieldsResponse a response format for the "fetch" request with requested fields,
+// which never contains the decrypted contents
+type FetchFieldsResponse struct {
+	Version string            `json:"version"`
+	Fields  map[string]string `json:"fields"`
+	Meta    *backend.Metadata `json:"meta,omitempty"`
+}
+
+// MakeFetchFieldsResponse initializes an empty fetch response with requested fields
+func MakeFetchFieldsResponse() *FetchFieldsResponse {
+	return &FetchFieldsResponse{
+		Fields: make(map[string]string),
+	}
+}
+
+// GenerateResponse a response format for the "generate" request
+type GenerateResponse struct {
+	Password string           `json:"password,omitempty"`
+	Policy   *generate.Policy `json:"policy"`
+	Version  string           `json:"version,omitempty"`
+	Commit   string           `json:"commit,omitempty"`
+}
+
+// MakeGenerateResponse initializes an empty generate response
+func MakeGenerateResponse() *GenerateResponse {
+	return &GenerateResponse{}
+}
+
+// ProbeResponse a response format for the "probe" request
+type ProbeResponse struct {
+	State       string                       `json:"state,omitempty"`
+	Stores      map[string]string            `json:"stores,omitempty"`
+	Directories map[string]map[string]string `json:"directories,omitempty"`
+}
+
+// MakeProbeResponse initializes an empty probe response
+func MakeProbeResponse() *ProbeResponse {
+	return &ProbeResponse{
+		Stores:      make(map[string]string),
+		Directories: make(map[string]map[string]string),
+	}
+}
+
+// OtpResponse a response format for the "otp" request
+type OtpResponse struct {
+	Type      string `json:"type"`
+	Code      string `json:"code"`
+	Remaining int    `json:"remaining,omitempty"`
+	Period    int    `json:"period,omitempty"`
+	Counter   uint64 `json:"counter,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+}
+
+// MakeOtpResponse initializes an empty otp response
+func MakeOtpResponse() *OtpResponse {
+	return &OtpResponse{}
+}
+
 // SaveResponse a response format for the "save" request
 type SaveResponse struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
 }
 
 // MakeSaveResponse initializes an empty save response
@@ -83,8 +208,20 @@ func MakeSaveResponse() *SaveResponse {
 	return &SaveResponse{}
 }
 
+// UpdateResponse a response format for the "update" request
+type UpdateResponse struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+// MakeUpdateResponse initializes an empty update response
+func MakeUpdateResponse() *UpdateResponse {
+	return &UpdateResponse{}
+}
+
 // DeleteResponse a response format for the "delete" request
 type DeleteResponse struct {
+	Commit string `json:"commit,omitempty"`
 }
 
 // MakeDeleteResponse initializes an empty delete response
@@ -92,6 +229,172 @@ func MakeDeleteResponse() *DeleteResponse {
 	return &DeleteResponse{}
 }
 
+// MovedFile a password file moved or copied by the host app
+type MovedFile struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Reencrypted bool   `json:"reencrypted"`
+}
+
+// MoveResponse a response format for the "move" request
+type MoveResponse struct {
+	Files  []MovedFile `json:"files"`
+	Commit string      `json:"commit,omitempty"`
+}
+
+// MakeMoveResponse initializes an empty move response
+func MakeMoveResponse() *MoveResponse {
+	return &MoveResponse{
+		Files: []MovedFile{},
+	}
+}
+
+// CopyResponse a response format for the "copy" request
+type CopyResponse struct {
+	Files  []MovedFile `json:"files"`
+	Commit string      `json:"commit,omitempty"`
+}
+
+// MakeCopyResponse initializes an empty copy response
+func MakeCopyResponse() *CopyResponse {
+	return &CopyResponse{
+		Files: []MovedFile{},
+	}
+}
+
+// ReencryptResponse a response format for the "reencrypt" request
+type ReencryptResponse struct {
+	Files   []string `json:"files"`
+	Checked int      `json:"checked"`
+	Commit  string   `json:"commit,omitempty"`
+}
+
+// MakeReencryptResponse initializes an empty reencrypt response
+func MakeReencryptResponse() *ReencryptResponse {
+	return &ReencryptResponse{
+		Files: []string{},
+	}
+}
+
+// HistoryVersion a previous version of a password file kept in the history
+type HistoryVersion struct {
+	Timestamp string `json:"timestamp"`
+	Date      string `json:"date"`
+}
+
+// HistoryResponse a response format for the "history" request
+type HistoryResponse struct {
+	Versions []HistoryVersion `json:"versions"`
+}
+
+// MakeHistoryResponse initializes an empty history response
+func MakeHistoryResponse() *HistoryResponse {
+	return &HistoryResponse{
+		Versions: []HistoryVersion{},
+	}
+}
+
+// RestoreResponse a response format for the "restore" request
+type RestoreResponse struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+// MakeRestoreResponse initializes an empty restore response
+func MakeRestoreResponse() *RestoreResponse {
+	return &RestoreResponse{}
+}
+
+// SyncResult the state of a git-backed password store after syncing it
+type SyncResult struct {
+	Branch    string   `json:"branch"`
+	Upstream  string   `json:"upstream"`
+	Ahead     int      `json:"ahead"`
+	Behind    int      `json:"behind"`
+	Dirty     bool     `json:"dirty"`
+	Pulled    bool     `json:"pulled"`
+	Pushed    bool     `json:"pushed"`
+	Conflicts []string `json:"conflicts"`
+}
+
+// MakeSyncResult initializes an empty sync result
+func MakeSyncResult() *SyncResult {
+	return &SyncResult{
+		Conflicts: []string{},
+	}
+}
+
+// SyncResponse a response format for the "sync" request
+type SyncResponse struct {
+	Stores map[string]*SyncResult `json:"stores"`
+}
+
+// MakeSyncResponse initializes an empty sync response
+func MakeSyncResponse() *SyncResponse {
+	return &SyncResponse{
+		Stores: make(map[string]*SyncResult),
+	}
+}
+
+// WatchResponse a response format for the "watch" request
+type WatchResponse struct {
+}
+
+// MakeWatchResponse initializes an empty watch response
+func MakeWatchResponse() *WatchResponse {
+	return &WatchResponse{}
+}
+
+// StoreChangedEvent an event format for the unsolicited "storeChanged" notifications
+type StoreChangedEvent struct {
+	StoreID  string   `json:"storeId"`
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// MakeStoreChangedEvent initializes an empty storeChanged event
+func MakeStoreChangedEvent(storeID string) *StoreChangedEvent {
+	return &StoreChangedEvent{
+		StoreID:  storeID,
+		Added:    []string{},
+		Removed:  []string{},
+		Modified: []string{},
+	}
+}
+
+// ReencryptProgressEvent an event format for the "reencryptProgress" notifications sent during the "reencrypt" request
+type ReencryptProgressEvent struct {
+	StoreID string `json:"storeId"`
+	File    string `json:"file"`
+	Stale   bool   `json:"stale"`
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+}
+
+// AbortedRequest is the panic value used to abort the current request in session mode
+type AbortedRequest struct {
+	Code errors.Code
+}
+
+var sendMutex sync.Mutex
+var sessionActive bool
+
+// StartSession switches to session mode, where errors abort only the current request instead of exiting
+func StartSession() {
+	sessionActive = true
+}
+
+// EndSession switches back to the default mode, where every error exits the app
+func EndSession() {
+	sessionActive = false
+}
+
+// IsSession checks whether the app is running in session mode
+func IsSession() bool {
+	return sessionActive
+}
+
 // SendOk sends a success response to the browser extension in the predefined json format
 func SendOk(data interface{}) {
 	SendRaw(&okResponse{
@@ -101,7 +404,8 @@ func SendOk(data interface{}) {
 	})
 }
 
-// SendErrorAndExit sends an error response to the browser extension in the predefined json format and exits with the specified exit code
+// SendErrorAndExit sends an error response to the browser extension in the predefined json format and exits with the specified exit code.
+// In session mode it aborts only the current request by panicking with AbortedRequest.
 func SendErrorAndExit(errorCode errors.Code, params *map[errors.Field]string) {
 	SendRaw(&errorResponse{
 		Status:  "error",
@@ -110,9 +414,23 @@ func SendErrorAndExit(errorCode errors.Code, params *map[errors.Field]string) {
 		Params:  params,
 	})
 
+	if sessionActive {
+		panic(AbortedRequest{Code: errorCode})
+	}
+
 	errors.ExitWithCode(errorCode)
 }
 
+// SendEvent sends an unsolicited event to the browser extension in the predefined json format
+func SendEvent(event string, data interface{}) {
+	SendRaw(&eventResponse{
+		Status:  "event",
+		Event:   event,
+		Version: version.Code,
+		Data:    data,
+	})
+}
+
 // SendRaw sends a raw data to the browser extension
 func SendRaw(response interface{}) {
 	var bytesBuffer bytes.Buffer
@@ -120,6 +438,9 @@ func SendRaw(response interface{}) {
 		log.Fatal("Unable to encode response for sending: ", err)
 	}
 
+	sendMutex.Lock()
+	defer sendMutex.Unlock()
+
 	if err := binary.Write(os.Stdout, binary.LittleEndian, uint32(bytesBuffer.Len())); err != nil {
 		log.Fatal("Unable to send the length of the response: ", err)
 	}
@@ -0,0 +1,72 @@
+package response
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_SendRaw_ConcurrentResponsesDoNotInterleave(t *testing.T) {
+	// Arrange
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unable to create a pipe for the responses: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	// The responses are larger than the pipe buffer, so each of them takes several writes
+	senders := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	messageLength := 256 * 1024
+
+	received := make(chan []string)
+	go func() {
+		messages := []string{}
+		for {
+			var length uint32
+			if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
+				received <- messages
+				return
+			}
+			message := make([]byte, length)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				received <- messages
+				return
+			}
+			var decoded string
+			if err := json.Unmarshal(message, &decoded); err != nil {
+				decoded = "invalid json"
+			}
+			messages = append(messages, decoded)
+		}
+	}()
+
+	// Act
+	var wg sync.WaitGroup
+	for _, sender := range senders {
+		wg.Add(1)
+		go func(sender string) {
+			defer wg.Done()
+			SendRaw(strings.Repeat(sender, messageLength))
+		}(sender)
+	}
+	wg.Wait()
+	writer.Close()
+
+	// Assert
+	messages := <-received
+	if len(messages) != len(senders) {
+		t.Fatalf("Expected %v responses, but got %v", len(senders), len(messages))
+	}
+
+	for _, message := range messages {
+		if len(message) != messageLength || strings.Trim(message, message[:1]) != "" {
+			t.Fatalf("Expected each response to be sent as a whole, but got an interleaved response")
+		}
+	}
+}